@@ -0,0 +1,79 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func adminRequest(t *testing.T, adminPort int, method string, path string, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, "http://localhost:"+strconv.Itoa(adminPort)+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %s", err.Error())
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	return res.StatusCode
+}
+
+func TestAdminAPI(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	adminPort, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for admin API")
+	}
+	c := testUtils.GetConfig(port, constants.RoundRobin)
+	c.Admin = config.Admin{Port: adminPort, Token: "secret", Persist: true}
+	otherServers := testUtils.StartServers(nil, ports[2:])
+	defer testUtils.StopServers(otherServers)
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports[:2], c, nil)
+	defer teardownSuite(t)
+
+	// A server is added to the pool and another one is taken out
+	status := adminRequest(t, adminPort, http.MethodPost, "/servers", `{"url": "http://localhost:`+ports[2]+`"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	status = adminRequest(t, adminPort, http.MethodPost, "/servers/localhost:"+ports[0]+"/down", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+	}, port)
+
+	// The changes are written back to the config file
+	content, err := os.ReadFile("../config-test.json")
+	if err != nil {
+		t.Fatalf("Error reading config file: %s", err.Error())
+	}
+	var persisted config.Config
+	if err := json.Unmarshal(content, &persisted); err != nil {
+		t.Fatalf("Error parsing config file: %s", err.Error())
+	}
+	if len(persisted.Servers) != 3 || !persisted.Servers[0].Disabled || persisted.Servers[2].Url != "http://localhost:"+ports[2] {
+		t.Fatalf("Expected the changes to be persisted, got %+v", persisted.Servers)
+	}
+}
@@ -0,0 +1,42 @@
+package e2e_tests
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestConsistentHashing(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.ConsistentHashing)
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports, config, nil)
+	defer teardownSuite(t)
+
+	// Requests from the same IP should always go to the same server, even over new connections
+	testUtils.AssertSameServerForClient(t, 5, port)
+}
+
+func TestConsistentHashingNoServersAreStarted(t *testing.T) {
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.ConsistentHashing)
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, []string{}, config, nil)
+	defer teardownSuite(t)
+
+	res, err := http.Get("http://localhost:" + strconv.Itoa(port))
+	if err != nil {
+		t.Fatalf("Error sending request to load balancer: %s", err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected service unavailable status code, got %d", res.StatusCode)
+	}
+}
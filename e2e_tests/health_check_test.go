@@ -0,0 +1,31 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestHealthCheckBody(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.RoundRobin)
+	config.HealthCheck.Path = "/ready"
+	config.HealthCheck.Body = "Hello from server " + ports[1]
+	config.HealthCheck.Timeout = "500ms"
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports, config, nil)
+	defer teardownSuite(t)
+
+	// Only the server whose health endpoint returns the expected body stays healthy
+	testCases := []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+	}
+
+	testUtils.AssertLoadBalancerResponse(t, testCases, port)
+}
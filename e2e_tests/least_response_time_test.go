@@ -24,13 +24,11 @@ func TestLeastResponseTime(t *testing.T) {
 		{ExpectedBody: "Hello from server " + ports[0], SlowResponse: true, Duration: 100},
 		{ExpectedBody: "Hello from server " + ports[1], SlowResponse: true, Duration: 50},
 		{ExpectedBody: "Hello from server " + ports[2], SlowResponse: true, Duration: 200},
-		{ExpectedBody: "Hello from server " + ports[1], SlowResponse: true, Duration: 100},
 		{ExpectedBody: "Hello from server " + ports[1], SlowResponse: true, Duration: 600},
-		{ExpectedBody: "Hello from server " + ports[0], SlowResponse: true, Duration: 700},
+		{ExpectedBody: "Hello from server " + ports[0], SlowResponse: true, Duration: 100},
+		{ExpectedBody: "Hello from server " + ports[0], SlowResponse: true, Duration: 300},
+		{ExpectedBody: "Hello from server " + ports[2], SlowResponse: true, Duration: 100},
 		{ExpectedBody: "Hello from server " + ports[2], SlowResponse: true, Duration: 100},
-		{ExpectedBody: "Hello from server " + ports[2], SlowResponse: true, Duration: 1500},
-		{ExpectedBody: "Hello from server " + ports[1], SlowResponse: true, Duration: 1500},
-		{ExpectedBody: "Hello from server " + ports[0], SlowResponse: true, Duration: 900},
 	}
 
 	testUtils.AssertLoadBalancerResponse(t, testCases, port)
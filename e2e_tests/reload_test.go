@@ -0,0 +1,48 @@
+package e2e_tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestReloadOnConfigFileChange(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.RoundRobin)
+	config.ConfigWatchInterval = "100ms"
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports[:1], config, nil)
+	defer teardownSuite(t)
+	otherServers := testUtils.StartServers(nil, ports[1:])
+	defer testUtils.StopServers(otherServers)
+
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+	}, port)
+
+	// The pool is replaced without a restart
+	testUtils.WriteConfigFile(config, ports[1:], []int{0, 0})
+	time.Sleep(time.Second)
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+	}, port)
+
+	// An invalid file is rejected and the running config is kept
+	if err := os.WriteFile("../config-test.json", []byte(`{"port": 0}`), 0644); err != nil {
+		t.Fatalf("Error writing config file: %s", err.Error())
+	}
+	time.Sleep(time.Second)
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+	}, port)
+}
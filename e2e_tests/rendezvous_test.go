@@ -0,0 +1,21 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestRendezvous(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.Rendezvous)
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports, config, nil)
+	defer teardownSuite(t)
+
+	testUtils.AssertSameServerForClient(t, 5, port)
+}
@@ -0,0 +1,83 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Error making request: %s", err.Error())
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	return res.StatusCode
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 1)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	c := testUtils.GetConfig(port, constants.RoundRobin)
+	c.Shutdown = config.Shutdown{GracePeriod: "5s", ReadinessPath: "/ready", ReadinessDelay: "1s"}
+	_, loadBalancer, port, teardownSuite := testUtils.SetupSuite(t, ports, c, nil)
+	defer teardownSuite(t)
+	baseURL := "http://localhost:" + strconv.Itoa(port)
+
+	if status := getStatus(t, baseURL+"/ready"); status != http.StatusOK {
+		t.Fatalf("Expected the load balancer to be ready, got %d", status)
+	}
+
+	// A slow request is still running when the load balancer is told to stop
+	done := make(chan error)
+	go func() {
+		res, err := http.Get(baseURL + "/slow?duration=2000")
+		if err != nil {
+			done <- err
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Hello from server "+ports[0]) {
+			done <- fmt.Errorf("unexpected response %d %s", res.StatusCode, body)
+			return
+		}
+		done <- nil
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if err := syscall.Kill(-loadBalancer.Process.Pid, syscall.SIGTERM); err != nil {
+		t.Fatalf("Error sending SIGTERM: %s", err.Error())
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// Readiness fails first, while requests are still served
+	if status := getStatus(t, baseURL+"/ready"); status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected readiness to fail while shutting down, got %d", status)
+	}
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[0]},
+	}, port)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the in-flight request to finish: %s", err.Error())
+	}
+	time.Sleep(500 * time.Millisecond)
+	if _, err := http.Get(baseURL); err == nil {
+		t.Fatalf("Expected the load balancer to be stopped")
+	}
+}
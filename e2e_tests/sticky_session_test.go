@@ -0,0 +1,55 @@
+package e2e_tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"testing"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestStickySession(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 3)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	config := testUtils.GetConfig(port, constants.RoundRobin)
+	config.StickySession.Enabled = true
+	config.StickySession.Secret = "secret"
+	_, _, port, teardownSuite := testUtils.SetupSuite(t, ports, config, nil)
+	defer teardownSuite(t)
+
+	// With the cookie, round robin only decides the first pick
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	expectedBody := ""
+	for i := 0; i < 5; i++ {
+		res, err := client.Get("http://localhost:" + strconv.Itoa(port))
+		if err != nil {
+			t.Fatalf("Error making request: %s", err.Error())
+		}
+		resBody, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("Error reading response body: %s", err.Error())
+		}
+		if expectedBody == "" {
+			expectedBody = string(resBody)
+		}
+		if string(resBody) != expectedBody {
+			t.Fatalf("Test case %d: Expected %s, got %s", i, expectedBody, resBody)
+		}
+	}
+
+	// Without the cookie, requests are distributed again
+	testCases := []testUtils.TestCase{
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+	}
+	testUtils.AssertLoadBalancerResponse(t, testCases, port)
+}
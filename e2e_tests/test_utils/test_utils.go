@@ -160,7 +160,7 @@ func GetFreePorts(t *testing.T, n int) []string {
 	for i := 0; i < n; i++ {
 		port, err := GetFreePort()
 		if err != nil {
-			t.Fatalf(err.Error())
+			t.Fatal(err.Error())
 		}
 		ports = append(ports, strconv.Itoa(port))
 	}
@@ -207,12 +207,12 @@ func AssertLoadBalancerResponseAsync(t *testing.T, testCases []TestCase, port in
 			}
 			res, err := http.Get("http://localhost:" + strconv.Itoa(port) + path)
 			if err != nil {
-				t.Fatalf("Error making request: %s", err.Error())
+				t.Errorf("Error making request: %s", err.Error())
 				return
 			}
 			resBody, err := io.ReadAll(res.Body)
 			if err != nil {
-				t.Fatalf("Error reading response body: %s", err.Error())
+				t.Errorf("Error reading response body: %s", err.Error())
 			}
 			defer res.Body.Close()
 			responses = append(responses, string(resBody))
@@ -228,6 +228,11 @@ func GetConfig(port int, strategy constants.Strategy) config.Config {
 		Port:                port,
 		Strategy:            strategy,
 		HealthCheckInterval: "1s",
+		// React to a single probe, so tests don't have to wait for several
+		HealthCheck: config.HealthCheck{
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
 	}
 }
 
@@ -245,6 +250,32 @@ func AssertLoadBalancerStatusCode(t *testing.T, testCases []TestCase, port int)
 	}
 }
 
+// AssertSameServerForClient sends requests over new connections, so every request
+// comes from a different client port, and checks that they all reach the same server
+func AssertSameServerForClient(t *testing.T, requestsCount int, port int) {
+	t.Helper()
+
+	expectedBody := ""
+	for i := 0; i < requestsCount; i++ {
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		res, err := client.Get("http://localhost:" + strconv.Itoa(port))
+		if err != nil {
+			t.Fatalf("Error making request: %s", err.Error())
+		}
+		resBody, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("Error reading response body: %s", err.Error())
+		}
+		if expectedBody == "" {
+			expectedBody = string(resBody)
+		}
+		if string(resBody) != expectedBody {
+			t.Fatalf("Test case %d: Expected %s, got %d %s", i, expectedBody, res.StatusCode, resBody)
+		}
+	}
+}
+
 func PrettyPrint(i interface{}) string {
 	s, _ := json.MarshalIndent(i, "", "\t")
 	return string(s)
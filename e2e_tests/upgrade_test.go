@@ -0,0 +1,127 @@
+package e2e_tests
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	testUtils "github.com/tiny-loadbalancer/e2e_tests/test_utils"
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func readPid(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+
+	return pid
+}
+
+func TestUpgrade(t *testing.T) {
+	ports := testUtils.GetFreePorts(t, 2)
+	port, err := testUtils.GetFreePort()
+	if err != nil {
+		t.Fatalf("Error getting free port for load balancer")
+	}
+	servers := testUtils.StartServers(nil, ports)
+	defer testUtils.StopServers(servers)
+
+	// The upgrade starts the binary of the running process again, so it can't run through go run
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "tiny-loadbalancer")
+	if out, err := exec.Command("go", "build", "-o", binary, "..").CombinedOutput(); err != nil {
+		t.Fatalf("Error building load balancer: %s %s", err.Error(), out)
+	}
+	pidFile := filepath.Join(dir, "tiny-loadbalancer.pid")
+	c := testUtils.GetConfig(port, constants.RoundRobin)
+	c.PidFile = pidFile
+	testUtils.WriteConfigFile(c, ports, []int{0, 0})
+	defer os.Remove("../config-test.json")
+
+	old := exec.Command(binary, "../config-test.json")
+	old.Stdout, old.Stderr = os.Stdout, os.Stderr
+	if err := old.Start(); err != nil {
+		t.Fatalf("Error starting load balancer: %s", err.Error())
+	}
+	defer func() {
+		if pid := readPid(pidFile); pid != 0 {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+		old.Process.Kill()
+	}()
+	time.Sleep(3 * time.Second)
+	if readPid(pidFile) != old.Process.Pid {
+		t.Fatalf("Expected the pid file to point to the running process")
+	}
+
+	// Every request opens a new connection while the sockets change hands
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var requests, failures atomic.Int64
+	var firstFailure atomic.Value
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				requests.Add(1)
+				res, err := client.Get("http://localhost:" + strconv.Itoa(port))
+				if err != nil {
+					failures.Add(1)
+					firstFailure.CompareAndSwap(nil, err.Error())
+					continue
+				}
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					failures.Add(1)
+					firstFailure.CompareAndSwap(nil, res.Status)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if err := syscall.Kill(old.Process.Pid, syscall.SIGUSR2); err != nil {
+		t.Fatalf("Error sending SIGUSR2: %s", err.Error())
+	}
+	exited := make(chan error)
+	go func() {
+		exited <- old.Wait()
+	}()
+	select {
+	case <-exited:
+	case <-time.After(15 * time.Second):
+		t.Fatalf("Expected the old process to exit after the upgrade")
+	}
+	time.Sleep(500 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	if failures.Load() > 0 {
+		t.Fatalf("Expected no failed requests during the upgrade, got %d of %d, first: %v", failures.Load(), requests.Load(), firstFailure.Load())
+	}
+	newPid := readPid(pidFile)
+	if newPid == 0 || newPid == old.Process.Pid {
+		t.Fatalf("Expected the pid file to point to the new process, got %d", newPid)
+	}
+	testUtils.AssertLoadBalancerResponse(t, []testUtils.TestCase{
+		{ExpectedBody: "Hello from server"},
+		{ExpectedBody: "Hello from server"},
+	}, port)
+}
@@ -22,27 +22,27 @@ func TestRoundRobinWeighted(t *testing.T) {
 
 	testCases := []testUtils.TestCase{
 		// cycle 1
-		{ExpectedBody: "Hello from server " + ports[0]}, //4
-		{ExpectedBody: "Hello from server " + ports[1]}, // 2
-		{ExpectedBody: "Hello from server " + ports[2]}, // 1,
-		{ExpectedBody: "Hello from server " + ports[0]}, // 3
-		{ExpectedBody: "Hello from server " + ports[1]}, // 1
-		{ExpectedBody: "Hello from server " + ports[2]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 2
-		{ExpectedBody: "Hello from server " + ports[1]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 1
-		{ExpectedBody: "Hello from server " + ports[0]}, // 0
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[0]},
 		// cycle 2
-		{ExpectedBody: "Hello from server " + ports[0]}, //4
-		{ExpectedBody: "Hello from server " + ports[1]}, // 2
-		{ExpectedBody: "Hello from server " + ports[2]}, // 1,
-		{ExpectedBody: "Hello from server " + ports[0]}, // 3
-		{ExpectedBody: "Hello from server " + ports[1]}, // 1
-		{ExpectedBody: "Hello from server " + ports[2]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 2
-		{ExpectedBody: "Hello from server " + ports[1]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 1
-		{ExpectedBody: "Hello from server " + ports[0]}, // 0
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[0]},
 	}
 
 	testUtils.AssertLoadBalancerResponse(t, testCases, port)
@@ -97,20 +97,19 @@ func TestRoundRobinWeightedServerDiesAndComesBackOnline(t *testing.T) {
 		{ExpectedBody: "Hello from server " + ports[0]},
 		{ExpectedBody: "Hello from server " + ports[1]},
 		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
 		{ExpectedBody: "Hello from server " + ports[0]},
 		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[1]},
+		{ExpectedBody: "Hello from server " + ports[0]},
+		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
 		{ExpectedBody: "Hello from server " + ports[0]},
-
-		{ExpectedBody: "Hello from server " + ports[0]}, //4
-		{ExpectedBody: "Hello from server " + ports[1]}, // 2
-		{ExpectedBody: "Hello from server " + ports[2]}, // 1,
-		{ExpectedBody: "Hello from server " + ports[0]}, // 3
-		{ExpectedBody: "Hello from server " + ports[1]}, // 1
-		{ExpectedBody: "Hello from server " + ports[2]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 2
-		{ExpectedBody: "Hello from server " + ports[1]}, // 0
-		{ExpectedBody: "Hello from server " + ports[0]}, // 1
-		{ExpectedBody: "Hello from server " + ports[0]}, // 0
 	}
 
 	testUtils.AssertLoadBalancerResponse(t, testCases, port)
@@ -159,7 +158,7 @@ func TestRoundRobinWeightedRetryRequestTurnedOn(t *testing.T) {
 		{ExpectedBody: "Hello from server " + ports[1]},
 		{ExpectedBody: "Hello from server " + ports[2]},
 		{ExpectedBody: "Hello from server " + ports[1]},
-		{ExpectedBody: "Hello from server " + ports[2]},
+		{ExpectedBody: "Hello from server " + ports[1]},
 	}
 
 	testUtils.AssertLoadBalancerResponse(t, testCases, port)
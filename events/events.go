@@ -0,0 +1,41 @@
+// Package events is the public API for the events of the load balancer and its health checks.
+// Programs running the load balancer with tinylb.Main pass it a Bus and subscribe to it.
+package events
+
+import (
+	ievents "github.com/tiny-loadbalancer/internal/events"
+)
+
+type Type = ievents.Type
+
+const (
+	ServerUp   = ievents.ServerUp
+	ServerDown = ievents.ServerDown
+	// ServerEjected is emitted when outlier detection takes a server out of the pool
+	ServerEjected = ievents.ServerEjected
+	// ServerDrained is emitted when a draining server has no requests left or its drain timeout expired
+	ServerDrained  = ievents.ServerDrained
+	ConfigReloaded = ievents.ConfigReloaded
+	// PoolEmpty is emitted when the last healthy server goes down
+	PoolEmpty = ievents.PoolEmpty
+	// EjectionSkipped is emitted when outlier detection keeps a failing server, because it is the
+	// last available one or too many servers are out already
+	EjectionSkipped = ievents.EjectionSkipped
+)
+
+type Event = ievents.Event
+
+// Subscriber gets every event emitted on the bus it is subscribed to.
+// Notify is called synchronously, so it must not block.
+type Subscriber = ievents.Subscriber
+
+// SubscriberFunc turns a function into a Subscriber
+type SubscriberFunc = ievents.SubscriberFunc
+
+// Bus hands the events to its subscribers, Channel subscribes with a channel
+type Bus = ievents.Bus
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return ievents.NewBus()
+}
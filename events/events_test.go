@@ -0,0 +1,39 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/events"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/reload"
+)
+
+func TestBusChannel(t *testing.T) {
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+
+	c := &config.Config{
+		Port:                8080,
+		Strategy:            "round-robin",
+		HealthCheckInterval: "5s",
+		Servers:             []config.Server{{Url: "http://localhost:8081", Weight: 1, Draining: true}},
+	}
+	tlb, err := reload.NewLoadBalancer(c)
+	if err != nil {
+		t.Fatalf("Error building load balancer: %s", err.Error())
+	}
+	tlb.Events = bus
+	// A draining server without requests is reported as drained right away
+	tlb.GetRequestHandler()
+
+	select {
+	case e := <-ch:
+		if e.Type != events.ServerDrained || e.Server != "http://localhost:8081" {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the event of the load balancer on the channel")
+	}
+}
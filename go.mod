@@ -1,6 +1,6 @@
 module github.com/tiny-loadbalancer
 
-go 1.22.0
+go 1.24.0
 
 require (
 	github.com/go-playground/locales v0.14.1 // indirect
@@ -0,0 +1,359 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	lb "github.com/tiny-loadbalancer/internal/load_balancer"
+)
+
+// ErrInvalidConfig is returned by a Store when a change leaves the config invalid
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Store holds the running config. The admin API changes the load balancer by applying an edited copy of it,
+// so admin changes go through the same validation and reload as changes of the config file.
+type Store interface {
+	// Config returns a copy of the running config
+	Config() *config.Config
+	// Apply validates the config and switches the load balancer to it
+	Apply(c *config.Config) error
+}
+
+// API is a JSON API to inspect and change the pool of a running load balancer.
+// Every request needs the token in an "Authorization: Bearer" header.
+type API struct {
+	LoadBalancer *lb.TinyLoadBalancer
+	Store        Store
+	Token        string
+	Logger       *slog.Logger
+	// mut keeps concurrent changes from overwriting each other
+	mut sync.Mutex
+}
+
+type addServerRequest struct {
+	Url    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+type weightRequest struct {
+	Weight *int `json:"weight"`
+}
+
+type strategyRequest struct {
+	Strategy constants.Strategy `json:"strategy"`
+}
+
+type retryRequestsRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", a.getStats)
+	mux.HandleFunc("GET /servers", a.getServers)
+	mux.HandleFunc("POST /servers", a.addServer)
+	mux.HandleFunc("GET /servers/{server}", a.getServer)
+	mux.HandleFunc("DELETE /servers/{server}", a.removeServer)
+	mux.HandleFunc("PUT /servers/{server}/weight", a.setWeight)
+	mux.HandleFunc("POST /servers/{server}/up", a.markUp)
+	mux.HandleFunc("POST /servers/{server}/down", a.markDown)
+	mux.HandleFunc("POST /servers/{server}/drain", a.drain)
+	mux.HandleFunc("PUT /strategy", a.setStrategy)
+	mux.HandleFunc("PUT /retry-requests", a.setRetryRequests)
+
+	return a.authorize(mux)
+}
+
+func (a *API) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// Without a configured token nobody gets in
+		if !ok || a.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *API) getStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.LoadBalancer.Stats())
+}
+
+func (a *API) getServers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.LoadBalancer.Stats().Servers)
+}
+
+func (a *API) getServer(w http.ResponseWriter, r *http.Request) {
+	a.writeServer(w, http.StatusOK, r.PathValue("server"))
+}
+
+func (a *API) addServer(w http.ResponseWriter, r *http.Request) {
+	var body addServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Url == "" {
+		writeError(w, http.StatusBadRequest, errors.New("url is required"))
+		return
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c := a.Store.Config()
+	if findServer(c, body.Url) != -1 {
+		writeError(w, http.StatusConflict, fmt.Errorf("server %s already exists", body.Url))
+		return
+	}
+	c.Servers = append(c.Servers, config.Server{Url: body.Url, Weight: body.Weight})
+	if !a.apply(w, c, "Added server", body.Url) {
+		return
+	}
+
+	a.writeServer(w, http.StatusCreated, body.Url)
+}
+
+func (a *API) removeServer(w http.ResponseWriter, r *http.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c, i, ok := a.getConfigServer(w, r)
+	if !ok {
+		return
+	}
+	removed := c.Servers[i].Url
+	c.Servers = slices.Delete(c.Servers, i, i+1)
+	if !a.apply(w, c, "Removed server", removed) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) setWeight(w http.ResponseWriter, r *http.Request) {
+	var body weightRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Weight == nil {
+		writeError(w, http.StatusBadRequest, errors.New("weight is required"))
+		return
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c, i, ok := a.getConfigServer(w, r)
+	if !ok {
+		return
+	}
+	c.Servers[i].Weight = *body.Weight
+	if !a.apply(w, c, "Changed server weight", c.Servers[i].Url) {
+		return
+	}
+
+	a.writeServer(w, http.StatusOK, c.Servers[i].Url)
+}
+
+// markUp enables the server, ends its drain and marks it as healthy right away,
+// its health checks take it out again if it is still down
+func (a *API) markUp(w http.ResponseWriter, r *http.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c, i, ok := a.getConfigServer(w, r)
+	if !ok {
+		return
+	}
+	c.Servers[i].Disabled = false
+	c.Servers[i].Draining = false
+	if !a.apply(w, c, "Marked server up", c.Servers[i].Url) {
+		return
+	}
+	for _, s := range a.LoadBalancer.GetServers() {
+		if matchesServer(s.URL.String(), c.Servers[i].Url) {
+			s.MarkUp()
+		}
+	}
+
+	a.writeServer(w, http.StatusOK, c.Servers[i].Url)
+}
+
+// markDown disables the server, it gets no requests until it is marked up again
+func (a *API) markDown(w http.ResponseWriter, r *http.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c, i, ok := a.getConfigServer(w, r)
+	if !ok {
+		return
+	}
+	c.Servers[i].Disabled = true
+	if !a.apply(w, c, "Marked server down", c.Servers[i].Url) {
+		return
+	}
+
+	a.writeServer(w, http.StatusOK, c.Servers[i].Url)
+}
+
+// drain stops new requests to the server and lets the ones in flight finish,
+// the drained field of the server turns true once they did
+func (a *API) drain(w http.ResponseWriter, r *http.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c, i, ok := a.getConfigServer(w, r)
+	if !ok {
+		return
+	}
+	c.Servers[i].Draining = true
+	if !a.apply(w, c, "Draining server", c.Servers[i].Url) {
+		return
+	}
+
+	a.writeServer(w, http.StatusOK, c.Servers[i].Url)
+}
+
+func (a *API) setStrategy(w http.ResponseWriter, r *http.Request) {
+	var body strategyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c := a.Store.Config()
+	c.Strategy = body.Strategy
+	if !a.apply(w, c, "Changed strategy", string(body.Strategy)) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.LoadBalancer.Stats())
+}
+
+func (a *API) setRetryRequests(w http.ResponseWriter, r *http.Request) {
+	var body retryRequestsRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Enabled == nil {
+		writeError(w, http.StatusBadRequest, errors.New("enabled is required"))
+		return
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	c := a.Store.Config()
+	c.RetryRequests = *body.Enabled
+	if !a.apply(w, c, "Changed retry requests", fmt.Sprint(*body.Enabled)) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.LoadBalancer.Stats())
+}
+
+// getConfigServer returns a copy of the running config and the index of the server in the path,
+// it writes a 404 when there is no such server
+func (a *API) getConfigServer(w http.ResponseWriter, r *http.Request) (*config.Config, int, bool) {
+	c := a.Store.Config()
+	id := r.PathValue("server")
+	i := findServer(c, id)
+	if i == -1 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("server %s not found", id))
+		return nil, -1, false
+	}
+
+	return c, i, true
+}
+
+// apply switches to the changed config, it writes the error response when that fails
+func (a *API) apply(w http.ResponseWriter, c *config.Config, message string, value string) bool {
+	err := a.Store.Apply(c)
+	if errors.Is(err, ErrInvalidConfig) {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+
+	a.getLogger().Info(message, slog.Attr{
+		Key:   "value",
+		Value: slog.StringValue(value),
+	})
+
+	return true
+}
+
+func (a *API) writeServer(w http.ResponseWriter, status int, id string) {
+	for _, s := range a.LoadBalancer.Stats().Servers {
+		if matchesServer(s.URL, id) {
+			writeJSON(w, status, s)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Errorf("server %s not found", id))
+}
+
+func (a *API) getLogger() *slog.Logger {
+	if a.Logger != nil {
+		return a.Logger
+	}
+
+	return slog.Default()
+}
+
+// findServer returns the index of the server in the config, or -1 when there is none
+func findServer(c *config.Config, id string) int {
+	return slices.IndexFunc(c.Servers, func(s config.Server) bool {
+		return matchesServer(s.Url, id)
+	})
+}
+
+// matchesServer reports whether id names the server, either by its URL or by its host and port
+func matchesServer(rawURL string, id string) bool {
+	if rawURL == id {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == id || u.String() == id
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,245 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	lb "github.com/tiny-loadbalancer/internal/load_balancer"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+// testStore applies configs by reloading the load balancer with their servers and settings
+type testStore struct {
+	tlb    *lb.TinyLoadBalancer
+	config *config.Config
+}
+
+func (s *testStore) Config() *config.Config {
+	c := *s.config
+	c.Servers = slices.Clone(s.config.Servers)
+
+	return &c
+}
+
+func (s *testStore) Apply(c *config.Config) error {
+	if err := c.ValidateConfig(c); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	next := &lb.TinyLoadBalancer{
+		Strategy:      c.Strategy,
+		RetryRequests: c.RetryRequests,
+	}
+	for _, cs := range c.Servers {
+		u, err := url.Parse(cs.Url)
+		if err != nil {
+			return err
+		}
+		s := server.NewServer(u, cs.Weight)
+		s.Disabled = cs.Disabled
+		s.SetDraining(cs.Draining)
+		next.Servers = append(next.Servers, s)
+	}
+	if _, _, err := s.tlb.Reload(next, c.Strategy != s.config.Strategy); err != nil {
+		return err
+	}
+	s.config = c
+
+	return nil
+}
+
+func newTestAPI(t *testing.T) (*httptest.Server, *lb.TinyLoadBalancer) {
+	c := &config.Config{
+		Port:                8080,
+		Strategy:            constants.RoundRobin,
+		HealthCheckInterval: "5s",
+		Servers: []config.Server{
+			{Url: "http://localhost:8081", Weight: 1},
+			{Url: "http://localhost:8082", Weight: 1},
+		},
+	}
+	tlb := &lb.TinyLoadBalancer{Strategy: constants.RoundRobin}
+	store := &testStore{tlb: tlb, config: &config.Config{}}
+	if err := store.Apply(c); err != nil {
+		t.Fatalf("Error applying config: %s", err.Error())
+	}
+	api := &API{LoadBalancer: tlb, Store: store, Token: "secret"}
+	ts := httptest.NewServer(api.Handler())
+	t.Cleanup(ts.Close)
+
+	return ts, tlb
+}
+
+func doRequest(t *testing.T, ts *httptest.Server, method string, path string, body string, token string) (int, string) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %s", err.Error())
+	}
+	defer res.Body.Close()
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Error reading response: %s", err.Error())
+	}
+
+	return res.StatusCode, string(content)
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	ts, _ := newTestAPI(t)
+
+	for _, token := range []string{"", "wrong"} {
+		status, _ := doRequest(t, ts, http.MethodGet, "/servers", "", token)
+		if status != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401 for token %q, got %d", token, status)
+		}
+	}
+	status, body := doRequest(t, ts, http.MethodGet, "/servers", "", "secret")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if !strings.Contains(body, `"url":"http://localhost:8081"`) || !strings.Contains(body, `"activeConnections":0`) {
+		t.Fatalf("Expected the servers to be listed, got %s", body)
+	}
+}
+
+func TestAPIChangesPool(t *testing.T) {
+	ts, tlb := newTestAPI(t)
+
+	status, body := doRequest(t, ts, http.MethodPost, "/servers", `{"url": "http://localhost:8083", "weight": 2}`, "secret")
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d %s", status, body)
+	}
+	status, _ = doRequest(t, ts, http.MethodPost, "/servers", `{"url": "http://localhost:8083"}`, "secret")
+	if status != http.StatusConflict {
+		t.Fatalf("Expected status 409 for a server that exists, got %d", status)
+	}
+	status, body = doRequest(t, ts, http.MethodPut, "/servers/localhost:8083/weight", `{"weight": 5}`, "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"weight":5`) {
+		t.Fatalf("Expected the weight to change, got %d %s", status, body)
+	}
+	status, _ = doRequest(t, ts, http.MethodPut, "/servers/localhost:8083/weight", `{"weight": -1}`, "secret")
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for a negative weight, got %d", status)
+	}
+	status, _ = doRequest(t, ts, http.MethodDelete, "/servers/"+url.PathEscape("http://localhost:8081"), "", "secret")
+	if status != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", status)
+	}
+	status, _ = doRequest(t, ts, http.MethodDelete, "/servers/localhost:8081", "", "secret")
+	if status != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for a removed server, got %d", status)
+	}
+
+	servers := tlb.GetServers()
+	if len(servers) != 2 || servers[0].URL.Host != "localhost:8082" || servers[1].URL.Host != "localhost:8083" {
+		t.Fatalf("Expected the pool to be updated, got %v", servers)
+	}
+	if servers[1].Weight != 5 {
+		t.Fatalf("Expected weight 5, got %d", servers[1].Weight)
+	}
+}
+
+func TestAPIMarksServerDownAndUp(t *testing.T) {
+	ts, tlb := newTestAPI(t)
+	s := tlb.GetServers()[0]
+
+	status, body := doRequest(t, ts, http.MethodPost, "/servers/localhost:8081/down", "", "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"disabled":true`) {
+		t.Fatalf("Expected the server to be disabled, got %d %s", status, body)
+	}
+	if s.IsAvailable() {
+		t.Fatalf("Expected a disabled server to be unavailable")
+	}
+	// Health checks can't bring a disabled server back
+	s.SetHealthy(true)
+	if s.IsAvailable() {
+		t.Fatalf("Expected a disabled server to stay unavailable")
+	}
+
+	s.SetHealthy(false)
+	status, body = doRequest(t, ts, http.MethodPost, "/servers/localhost:8081/up", "", "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"disabled":false`) {
+		t.Fatalf("Expected the server to be enabled, got %d %s", status, body)
+	}
+	if !s.IsAvailable() {
+		t.Fatalf("Expected the server to be available")
+	}
+}
+
+func TestAPIDrainsServer(t *testing.T) {
+	ts, tlb := newTestAPI(t)
+	s := tlb.GetServers()[0]
+
+	status, body := doRequest(t, ts, http.MethodPost, "/servers/localhost:8081/drain", "", "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"draining":true`) || !strings.Contains(body, `"drained":true`) {
+		t.Fatalf("Expected an idle server to be drained right away, got %d %s", status, body)
+	}
+	if s.IsAvailable() {
+		t.Fatalf("Expected a draining server to be unavailable")
+	}
+
+	status, body = doRequest(t, ts, http.MethodPost, "/servers/localhost:8081/up", "", "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"draining":false`) {
+		t.Fatalf("Expected the drain to end, got %d %s", status, body)
+	}
+	if !s.IsAvailable() {
+		t.Fatalf("Expected the server to be available")
+	}
+}
+
+func TestAPIChangesSettings(t *testing.T) {
+	ts, tlb := newTestAPI(t)
+
+	status, body := doRequest(t, ts, http.MethodPut, "/strategy", `{"strategy": "least-connections"}`, "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"strategy":"least-connections"`) {
+		t.Fatalf("Expected the strategy to change, got %d %s", status, body)
+	}
+	status, _ = doRequest(t, ts, http.MethodPut, "/strategy", `{"strategy": "unknown"}`, "secret")
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an unknown strategy, got %d", status)
+	}
+	status, body = doRequest(t, ts, http.MethodPut, "/retry-requests", `{"enabled": true}`, "secret")
+	if status != http.StatusOK || !strings.Contains(body, `"retryRequests":true`) {
+		t.Fatalf("Expected retries to be enabled, got %d %s", status, body)
+	}
+
+	stats := tlb.Stats()
+	if stats.Strategy != constants.LeastConnections || !stats.RetryRequests {
+		t.Fatalf("Expected the settings to change, got %+v", stats)
+	}
+}
+
+func TestAPIStoreError(t *testing.T) {
+	tlb := &lb.TinyLoadBalancer{Strategy: constants.RoundRobin}
+	api := &API{LoadBalancer: tlb, Store: failingStore{}, Token: "secret"}
+	ts := httptest.NewServer(api.Handler())
+	defer ts.Close()
+
+	status, _ := doRequest(t, ts, http.MethodPut, "/retry-requests", `{"enabled": true}`, "secret")
+	if status != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", status)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Config() *config.Config {
+	return &config.Config{}
+}
+
+func (failingStore) Apply(c *config.Config) error {
+	return errors.New("disk full")
+}
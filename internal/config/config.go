@@ -2,24 +2,163 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/health"
+	"github.com/tiny-loadbalancer/internal/strategy"
 	"gopkg.in/go-playground/validator.v9"
 )
 
 type Server struct {
-	Url    string `json:"url" validate:"required,url"`
-	Weight int    `json:"weight"`
+	Url            string          `json:"url" validate:"required,url"`
+	Weight         int             `json:"weight" validate:"gte=0"`
+	SlowStart      *SlowStart      `json:"slowStart,omitempty"`
+	HealthCheck    *HealthCheck    `json:"healthCheck,omitempty"`
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+	Disabled       bool            `json:"disabled,omitempty"`
+	Draining       bool            `json:"draining,omitempty"`
+}
+
+type HealthCheck struct {
+	Type               string            `json:"type,omitempty" validate:"omitempty,oneof=http tcp grpc tls script"`
+	Path               string            `json:"path,omitempty" validate:"omitempty,startswith=/"`
+	Method             string            `json:"method,omitempty" validate:"omitempty,oneof=GET HEAD POST OPTIONS"`
+	Host               string            `json:"host,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	ExpectedStatuses   []string          `json:"expectedStatuses,omitempty" validate:"dive,statusRange"`
+	Body               string            `json:"body,omitempty"`
+	BodyRegex          string            `json:"bodyRegex,omitempty" validate:"omitempty,regexp"`
+	Send               string            `json:"send,omitempty"`
+	Expect             string            `json:"expect,omitempty"`
+	Service            string            `json:"service,omitempty"`
+	MinCertValidity    string            `json:"minCertValidity,omitempty" validate:"omitempty,duration"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`
+	Command            []string          `json:"command,omitempty"`
+	Timeout            string            `json:"timeout,omitempty" validate:"omitempty,duration"`
+	UnhealthyInterval  string            `json:"unhealthyInterval,omitempty" validate:"omitempty,duration"`
+	Jitter             string            `json:"jitter,omitempty" validate:"omitempty,duration"`
+	HealthyThreshold   int               `json:"healthyThreshold,omitempty" validate:"gte=0"`
+	UnhealthyThreshold int               `json:"unhealthyThreshold,omitempty" validate:"gte=0"`
+}
+
+type SlowStart struct {
+	Duration         string  `json:"duration,omitempty" validate:"omitempty,duration"`
+	Curve            float64 `json:"curve,omitempty" validate:"gte=0"`
+	MinWeightPercent float64 `json:"minWeightPercent,omitempty" validate:"gte=0,lte=100"`
+}
+
+type CircuitBreaker struct {
+	Enabled             bool    `json:"enabled,omitempty"`
+	ConsecutiveFailures int     `json:"consecutiveFailures,omitempty" validate:"gte=0"`
+	ErrorRatePercent    float64 `json:"errorRatePercent,omitempty" validate:"gte=0,lte=100"`
+	MinRequests         int     `json:"minRequests,omitempty" validate:"gte=0"`
+	Window              string  `json:"window,omitempty" validate:"omitempty,duration"`
+	Cooldown            string  `json:"cooldown,omitempty" validate:"omitempty,duration"`
+	HalfOpenRequests    int     `json:"halfOpenRequests,omitempty" validate:"gte=0"`
+}
+
+type HashKey struct {
+	Source            string                    `json:"source,omitempty" validate:"omitempty,hashKey"`
+	Fallback          constants.HashKeyFallback `json:"fallback,omitempty" validate:"omitempty,oneof=client-ip random"`
+	TrustForwardedFor bool                      `json:"trustForwardedFor,omitempty"`
+}
+
+type StickySession struct {
+	Enabled      bool   `json:"enabled,omitempty"`
+	CookieName   string `json:"cookieName,omitempty"`
+	TTL          string `json:"ttl,omitempty" validate:"omitempty,duration"`
+	Secure       bool   `json:"secure,omitempty"`
+	HTTPOnly     bool   `json:"httpOnly,omitempty"`
+	SameSite     string `json:"sameSite,omitempty" validate:"omitempty,oneof=lax strict none"`
+	Secret       string `json:"secret,omitempty"`
+	KeepDraining bool   `json:"keepDraining,omitempty"`
+}
+
+type LeastResponseTime struct {
+	HalfLife  string `json:"halfLife,omitempty" validate:"omitempty,duration"`
+	Probation string `json:"probation,omitempty" validate:"omitempty,duration"`
+}
+
+type RetryBudget struct {
+	Ratio      float64 `json:"ratio,omitempty" validate:"gte=0,lte=1"`
+	MinRetries int     `json:"minRetries,omitempty" validate:"gte=0"`
+	Window     string  `json:"window,omitempty" validate:"omitempty,duration"`
+}
+
+type RetryBackoff struct {
+	BaseInterval string `json:"baseInterval,omitempty" validate:"omitempty,duration"`
+	MaxInterval  string `json:"maxInterval,omitempty" validate:"omitempty,duration"`
+}
+
+type RetryPolicy struct {
+	Methods       []string               `json:"methods,omitempty" validate:"dive,required"`
+	StatusCodes   []int                  `json:"statusCodes,omitempty" validate:"dive,gte=100,lte=599"`
+	Errors        []constants.RetryError `json:"errors,omitempty" validate:"dive,retryError"`
+	MaxAttempts   int                    `json:"maxAttempts,omitempty" validate:"gte=0"`
+	PerTryTimeout string                 `json:"perTryTimeout,omitempty" validate:"omitempty,duration"`
+	Budget        RetryBudget            `json:"budget,omitzero"`
+	Backoff       RetryBackoff           `json:"backoff,omitzero"`
+}
+
+type OutlierDetection struct {
+	Consecutive5xx           int     `json:"consecutive5xx,omitempty" validate:"gte=0"`
+	ConsecutiveGatewayErrors int     `json:"consecutiveGatewayErrors,omitempty" validate:"gte=0"`
+	Interval                 string  `json:"interval,omitempty" validate:"omitempty,duration"`
+	BaseEjectionTime         string  `json:"baseEjectionTime,omitempty" validate:"omitempty,duration"`
+	MaxEjectionTime          string  `json:"maxEjectionTime,omitempty" validate:"omitempty,duration"`
+	MaxEjectionPercent       int     `json:"maxEjectionPercent,omitempty" validate:"gte=0,lte=100"`
+	SuccessRateMinimumHosts  int     `json:"successRateMinimumHosts,omitempty" validate:"gte=0"`
+	SuccessRateRequestVolume int     `json:"successRateRequestVolume,omitempty" validate:"gte=0"`
+	SuccessRateStdevFactor   float64 `json:"successRateStdevFactor,omitempty" validate:"gte=0"`
+}
+
+type Webhook struct {
+	Url     string            `json:"url" validate:"required,url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Events  []string          `json:"events,omitempty" validate:"dive,oneof=server-up server-down server-ejected server-drained config-reloaded pool-empty ejection-skipped"`
+	Timeout string            `json:"timeout,omitempty" validate:"omitempty,duration"`
+}
+
+type Admin struct {
+	Port    int    `json:"port" validate:"gte=0"`
+	Host    string `json:"host,omitempty"`
+	Token   string `json:"token,omitempty" validate:"required_with=Port"`
+	Persist bool   `json:"persist,omitempty"`
+}
+
+type Shutdown struct {
+	GracePeriod    string `json:"gracePeriod,omitempty" validate:"omitempty,duration"`
+	ReadinessPath  string `json:"readinessPath,omitempty" validate:"omitempty,startswith=/"`
+	ReadinessDelay string `json:"readinessDelay,omitempty" validate:"omitempty,duration"`
 }
 
 type Config struct {
 	Port                int                `json:"port" validate:"gt=0"`
 	Servers             []Server           `json:"servers" validate:"dive,required"`
 	Strategy            constants.Strategy `json:"strategy" validate:"strategy"`
+	VirtualNodes        int                `json:"virtualNodes,omitempty" validate:"gte=0"`
+	HashKey             HashKey            `json:"hashKey,omitzero"`
+	StickySession       StickySession      `json:"stickySession,omitzero"`
+	LeastResponseTime   LeastResponseTime  `json:"leastResponseTime,omitzero"`
+	SlowStart           SlowStart          `json:"slowStart,omitzero"`
+	CircuitBreaker      CircuitBreaker     `json:"circuitBreaker,omitzero"`
 	HealthCheckInterval string             `json:"healthCheckInterval" validate:"healthCheckInterval"`
-	RetryRequests       bool               `json:"retryRequests"`
+	HealthCheck         HealthCheck        `json:"healthCheck,omitzero"`
+	ConfigWatchInterval string             `json:"configWatchInterval,omitempty" validate:"omitempty,duration"`
+	DrainTimeout        string             `json:"drainTimeout,omitempty" validate:"omitempty,duration"`
+	RetryRequests       bool               `json:"retryRequests,omitempty"`
+	MaxRetryBodyMemory  int64              `json:"maxRetryBodyMemory,omitempty" validate:"gte=0"`
+	MaxRetryBodySize    int64              `json:"maxRetryBodySize,omitempty" validate:"gte=0"`
+	RetryPolicy         RetryPolicy        `json:"retryPolicy,omitzero"`
+	OutlierDetection    OutlierDetection   `json:"outlierDetection,omitzero"`
+	Webhooks            []Webhook          `json:"webhooks,omitempty" validate:"dive"`
+	Admin               Admin              `json:"admin,omitzero"`
+	Shutdown            Shutdown           `json:"shutdown,omitzero"`
+	PidFile             string             `json:"pidFile,omitempty"`
 }
 
 func (c *Config) strategyValidatorFunc(fl validator.FieldLevel) bool {
@@ -28,14 +167,20 @@ func (c *Config) strategyValidatorFunc(fl validator.FieldLevel) bool {
 	return c.validateStrategy(strategy)
 }
 
-func (c *Config) validateStrategy(strategy string) bool {
-	for _, s := range constants.Strategies {
-		if strategy == string(s) {
-			return true
-		}
-	}
+func (c *Config) validateStrategy(s string) bool {
+	return strategy.Exists(constants.Strategy(s))
+}
 
-	return false
+func (c *Config) hashKeyValidatorFunc(fl validator.FieldLevel) bool {
+	source := fl.Field().String()
+
+	return c.validateHashKey(source)
+}
+
+func (c *Config) validateHashKey(source string) bool {
+	_, err := strategy.NewKeyFunc(source, constants.ClientIPFallback, false)
+
+	return err == nil
 }
 
 func (c *Config) healthCheckValidatorFunc(fl validator.FieldLevel) bool {
@@ -53,6 +198,75 @@ func (c *Config) validateHealthCheckInterval(interval string) bool {
 	return true
 }
 
+func (c *Config) retryErrorValidatorFunc(fl validator.FieldLevel) bool {
+	retryError := fl.Field().String()
+
+	return c.validateRetryError(retryError)
+}
+
+func (c *Config) validateRetryError(retryError string) bool {
+	for _, e := range constants.RetryErrors {
+		if retryError == string(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) durationValidatorFunc(fl validator.FieldLevel) bool {
+	duration := fl.Field().String()
+
+	return c.validateDuration(duration)
+}
+
+func (c *Config) validateDuration(duration string) bool {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return false
+	}
+
+	return d > 0
+}
+
+// weightsStructLevelValidation requires a weight on every server when the strategy uses them
+func (c *Config) weightsStructLevelValidation(sl validator.StructLevel) {
+	conf := sl.Current().Interface().(Config)
+	if !strategy.RequiresWeights(conf.Strategy) {
+		return
+	}
+
+	for i, s := range conf.Servers {
+		if s.Weight < 1 {
+			sl.ReportError(s.Weight, fmt.Sprintf("Servers[%d].Weight", i), "Weight", "weighted", "")
+		}
+	}
+}
+
+func (c *Config) statusRangeValidatorFunc(fl validator.FieldLevel) bool {
+	statusRange := fl.Field().String()
+
+	return c.validateStatusRange(statusRange)
+}
+
+func (c *Config) validateStatusRange(statusRange string) bool {
+	_, err := health.ParseStatusRange(statusRange)
+
+	return err == nil
+}
+
+func (c *Config) regexpValidatorFunc(fl validator.FieldLevel) bool {
+	expr := fl.Field().String()
+
+	return c.validateRegexp(expr)
+}
+
+func (c *Config) validateRegexp(expr string) bool {
+	_, err := regexp.Compile(expr)
+
+	return err == nil
+}
+
 func (c *Config) ReadConfig(path string) (*Config, error) {
 	var config *Config
 
@@ -73,6 +287,12 @@ func (c *Config) ValidateConfig(conf *Config) error {
 	validate := validator.New()
 	validate.RegisterValidation("strategy", c.strategyValidatorFunc)
 	validate.RegisterValidation("healthCheckInterval", c.healthCheckValidatorFunc)
+	validate.RegisterValidation("hashKey", c.hashKeyValidatorFunc)
+	validate.RegisterValidation("retryError", c.retryErrorValidatorFunc)
+	validate.RegisterValidation("duration", c.durationValidatorFunc)
+	validate.RegisterValidation("statusRange", c.statusRangeValidatorFunc)
+	validate.RegisterValidation("regexp", c.regexpValidatorFunc)
+	validate.RegisterStructValidation(c.weightsStructLevelValidation, Config{})
 
 	err := validate.Struct(conf)
 	if err != nil {
@@ -81,3 +301,30 @@ func (c *Config) ValidateConfig(conf *Config) error {
 
 	return nil
 }
+
+// OptionalDuration is a duration from the config, its destination keeps its value when it is empty
+type OptionalDuration struct {
+	value string
+	dest  *time.Duration
+}
+
+// Duration pairs a duration from the config with where ParseDurations stores it
+func Duration(value string, dest *time.Duration) OptionalDuration {
+	return OptionalDuration{value: value, dest: dest}
+}
+
+// ParseDurations parses the durations that are set, the others keep their defaults
+func ParseDurations(durations ...OptionalDuration) error {
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil {
+			return err
+		}
+		*d.dest = duration
+	}
+
+	return nil
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/tiny-loadbalancer/internal/constants"
 )
@@ -86,6 +88,52 @@ func TestValidateHealthCheck(t *testing.T) {
 	}
 }
 
+func TestValidateHealthCheckBlock(t *testing.T) {
+	testCases := []struct {
+		id          int
+		healthCheck HealthCheck
+		valid       bool
+	}{
+		{id: 1, healthCheck: HealthCheck{}, valid: true},
+		{id: 2, healthCheck: HealthCheck{Path: "/ready", Method: "HEAD", ExpectedStatuses: []string{"200-299", "418"}}, valid: true},
+		{id: 3, healthCheck: HealthCheck{Path: "ready"}, valid: false},
+		{id: 4, healthCheck: HealthCheck{Method: "DELETE"}, valid: false},
+		{id: 5, healthCheck: HealthCheck{ExpectedStatuses: []string{"2xx"}}, valid: false},
+		{id: 6, healthCheck: HealthCheck{BodyRegex: `"status":\s*"ok"`, Timeout: "2s"}, valid: true},
+		{id: 7, healthCheck: HealthCheck{BodyRegex: `(`}, valid: false},
+		{id: 8, healthCheck: HealthCheck{Timeout: "soon"}, valid: false},
+		{id: 9, healthCheck: HealthCheck{Type: "tcp", Send: "PING\r\n", Expect: "PONG"}, valid: true},
+		{id: 10, healthCheck: HealthCheck{Type: "udp"}, valid: false},
+		{id: 11, healthCheck: HealthCheck{Type: "tls", MinCertValidity: "week"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			HealthCheck:         tc.healthCheck,
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid && err != nil {
+			t.Fatalf("Test case %d: Expected health check to be valid, got %s", tc.id, err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("Test case %d: Expected health check to be invalid", tc.id)
+		}
+
+		// The same rules apply to the health check of a server
+		healthCheck := tc.healthCheck
+		c.HealthCheck = HealthCheck{}
+		c.Servers[0].HealthCheck = &healthCheck
+		err = c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected server health check to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
 func TestValidateServers(t *testing.T) {
 	c := &Config{
 		Servers: []Server{
@@ -113,3 +161,302 @@ func TestValidateServers(t *testing.T) {
 		t.Fatalf("Expected error for invalid server, got %s", err)
 	}
 }
+
+func TestValidateWeights(t *testing.T) {
+	testCases := []struct {
+		id       int
+		strategy constants.Strategy
+		weights  []int
+		valid    bool
+	}{
+		{id: 1, strategy: constants.RoundRobin, weights: []int{0, 0}, valid: true},
+		{id: 2, strategy: constants.RoundRobin, weights: []int{-1, 0}, valid: false},
+		{id: 3, strategy: constants.WeightedRoundRobin, weights: []int{5, 1}, valid: true},
+		{id: 4, strategy: constants.WeightedRoundRobin, weights: []int{5, 0}, valid: false},
+		{id: 5, strategy: constants.WeightedRandom, weights: []int{0, 2}, valid: false},
+		{id: 6, strategy: constants.WeightedLeastConnections, weights: []int{3, 2}, valid: true},
+		{id: 7, strategy: constants.P2C, weights: []int{0, 2}, valid: true},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Strategy:            tc.strategy,
+			HealthCheckInterval: "5s",
+			Port:                123,
+		}
+		for i, weight := range tc.weights {
+			c.Servers = append(c.Servers, Server{Url: fmt.Sprintf("http://localhost:%d", 8080+i), Weight: weight})
+		}
+
+		err := c.ValidateConfig(c)
+		if tc.valid && err != nil {
+			t.Fatalf("Test case %d: Expected config to be valid, got %s", tc.id, err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("Test case %d: Expected config to be invalid", tc.id)
+		}
+	}
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	testCases := []struct {
+		id          int
+		retryPolicy RetryPolicy
+		valid       bool
+	}{
+		{
+			id:          1,
+			retryPolicy: RetryPolicy{},
+			valid:       true,
+		},
+		{
+			id: 2,
+			retryPolicy: RetryPolicy{
+				Methods:       []string{"GET", "POST"},
+				StatusCodes:   []int{502, 503},
+				Errors:        []constants.RetryError{constants.RetryOnConnectFailure, constants.RetryOnTimeout},
+				MaxAttempts:   3,
+				PerTryTimeout: "2s",
+			},
+			valid: true,
+		},
+		{
+			id:          3,
+			retryPolicy: RetryPolicy{Errors: []constants.RetryError{"invalid-error"}},
+			valid:       false,
+		},
+		{
+			id:          4,
+			retryPolicy: RetryPolicy{StatusCodes: []int{1000}},
+			valid:       false,
+		},
+		{
+			id:          5,
+			retryPolicy: RetryPolicy{PerTryTimeout: "invalid-timeout"},
+			valid:       false,
+		},
+		{
+			id:          6,
+			retryPolicy: RetryPolicy{MaxAttempts: -1},
+			valid:       false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		c := &Config{
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			Port:                123,
+			RetryPolicy:         testCase.retryPolicy,
+		}
+		err := c.ValidateConfig(c)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("Test case %d: Expected valid to be %t, got error %v", testCase.id, testCase.valid, err)
+		}
+	}
+}
+
+func TestValidateOutlierDetection(t *testing.T) {
+	testCases := []struct {
+		id               int
+		outlierDetection OutlierDetection
+		valid            bool
+	}{
+		{id: 1, outlierDetection: OutlierDetection{}, valid: true},
+		{id: 2, outlierDetection: OutlierDetection{Consecutive5xx: 3, BaseEjectionTime: "10s", MaxEjectionPercent: 50}, valid: true},
+		{id: 3, outlierDetection: OutlierDetection{MaxEjectionPercent: 101}, valid: false},
+		{id: 4, outlierDetection: OutlierDetection{Consecutive5xx: -1}, valid: false},
+		{id: 5, outlierDetection: OutlierDetection{Interval: "often"}, valid: false},
+		{id: 6, outlierDetection: OutlierDetection{SuccessRateStdevFactor: -1}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			OutlierDetection:    tc.outlierDetection,
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected outlier detection to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
+func TestValidateCircuitBreaker(t *testing.T) {
+	testCases := []struct {
+		id             int
+		circuitBreaker CircuitBreaker
+		valid          bool
+	}{
+		{id: 1, circuitBreaker: CircuitBreaker{}, valid: true},
+		{id: 2, circuitBreaker: CircuitBreaker{Enabled: true, ConsecutiveFailures: 3, Cooldown: "10s"}, valid: true},
+		{id: 3, circuitBreaker: CircuitBreaker{ErrorRatePercent: 120}, valid: false},
+		{id: 4, circuitBreaker: CircuitBreaker{Window: "long"}, valid: false},
+		{id: 5, circuitBreaker: CircuitBreaker{HalfOpenRequests: -1}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			CircuitBreaker:      tc.circuitBreaker,
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected circuit breaker to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+
+		// The same rules apply to the circuit breaker of a server
+		circuitBreaker := tc.circuitBreaker
+		c.CircuitBreaker = CircuitBreaker{}
+		c.Servers[0].CircuitBreaker = &circuitBreaker
+		err = c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected server circuit breaker to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	testCases := []struct {
+		id      int
+		webhook Webhook
+		valid   bool
+	}{
+		{id: 1, webhook: Webhook{Url: "http://localhost:9000/events"}, valid: true},
+		{id: 2, webhook: Webhook{Url: "http://localhost:9000/events", Events: []string{"server-down", "pool-empty"}, Timeout: "2s"}, valid: true},
+		{id: 3, webhook: Webhook{}, valid: false},
+		{id: 4, webhook: Webhook{Url: "http://localhost:9000/events", Events: []string{"server-restarted"}}, valid: false},
+		{id: 5, webhook: Webhook{Url: "http://localhost:9000/events", Timeout: "later"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			Webhooks:            []Webhook{tc.webhook},
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected webhook to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
+func TestValidateAdmin(t *testing.T) {
+	testCases := []struct {
+		id    int
+		admin Admin
+		valid bool
+	}{
+		{id: 1, admin: Admin{}, valid: true},
+		{id: 2, admin: Admin{Port: 9090, Token: "secret", Persist: true}, valid: true},
+		{id: 3, admin: Admin{Port: 9090}, valid: false},
+		{id: 4, admin: Admin{Port: -1, Token: "secret"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			Admin:               tc.admin,
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected admin to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
+func TestValidateHashKey(t *testing.T) {
+	c := &Config{}
+	testCases := []struct {
+		id     int
+		input  string
+		output bool
+	}{
+		{
+			id:     1,
+			input:  "client-ip",
+			output: true,
+		},
+		{
+			id:     2,
+			input:  "header:X-User-ID",
+			output: true,
+		},
+		{
+			id:     3,
+			input:  "{cookie:session}-{path}",
+			output: true,
+		},
+		{
+			id:     4,
+			input:  "cookie",
+			output: false,
+		},
+		{
+			id:     5,
+			input:  "invalid-source",
+			output: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		res := c.validateHashKey(testCase.input)
+		if res != testCase.output {
+			t.Fatalf("Test case %d: Expected %t, got %t", testCase.id, testCase.output, res)
+		}
+	}
+}
+
+func TestValidateShutdown(t *testing.T) {
+	testCases := []struct {
+		id       int
+		shutdown Shutdown
+		valid    bool
+	}{
+		{id: 1, shutdown: Shutdown{}, valid: true},
+		{id: 2, shutdown: Shutdown{GracePeriod: "10s", ReadinessPath: "/ready", ReadinessDelay: "5s"}, valid: true},
+		{id: 3, shutdown: Shutdown{GracePeriod: "soon"}, valid: false},
+		{id: 4, shutdown: Shutdown{ReadinessPath: "ready"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		c := &Config{
+			Servers:             []Server{{Url: "http://localhost:8080"}},
+			Strategy:            constants.RoundRobin,
+			HealthCheckInterval: "5s",
+			Shutdown:            tc.shutdown,
+			Port:                123,
+		}
+		err := c.ValidateConfig(c)
+		if tc.valid != (err == nil) {
+			t.Fatalf("Test case %d: Expected shutdown to be valid: %t, got %v", tc.id, tc.valid, err)
+		}
+	}
+}
+
+func TestParseDurations(t *testing.T) {
+	timeout, interval := 5*time.Second, time.Duration(0)
+	err := ParseDurations(Duration("", &timeout), Duration("2m", &interval))
+	if err != nil {
+		t.Fatalf("Error parsing durations: %s", err.Error())
+	}
+	if timeout != 5*time.Second || interval != 2*time.Minute {
+		t.Fatalf("Expected empty durations to keep their defaults, got %s and %s", timeout, interval)
+	}
+
+	if err := ParseDurations(Duration("soon", &timeout)); err == nil {
+		t.Fatalf("Expected an error for an invalid duration")
+	}
+}
@@ -1,22 +1,160 @@
 package constants
 
+import "time"
+
+// Strategy is the name a strategy is registered under, see the strategy package
 type Strategy string
 
 const (
-	RoundRobin         Strategy = "round-robin"
-	Random             Strategy = "random"
-	WeightedRoundRobin Strategy = "weighted-round-robin"
-	IPHashing          Strategy = "ip-hashing"
-	LeastConnections   Strategy = "least-connections"
-	LeastResponseTime  Strategy = "least-response-time"
+	RoundRobin               Strategy = "round-robin"
+	Random                   Strategy = "random"
+	WeightedRoundRobin       Strategy = "weighted-round-robin"
+	IPHashing                Strategy = "ip-hashing"
+	LeastConnections         Strategy = "least-connections"
+	LeastResponseTime        Strategy = "least-response-time"
+	ConsistentHashing        Strategy = "consistent-hashing"
+	Maglev                   Strategy = "maglev"
+	Rendezvous               Strategy = "rendezvous"
+	P2C                      Strategy = "p2c"
+	P2CLatency               Strategy = "p2c-latency"
+	WeightedRandom           Strategy = "weighted-random"
+	WeightedLeastConnections Strategy = "weighted-least-connections"
+)
+
+const (
+	// Time after which a response time counts half as much in the latency moving average
+	DefaultLatencyHalfLife = 10 * time.Second
+	// Time after a server joins or recovers, during which least-response-time assumes it is as fast as the rest of the pool
+	DefaultLatencyProbation = 30 * time.Second
+)
+
+const (
+	// Slow start ramps up linearly by default
+	DefaultSlowStartCurve = 1.0
+	// Share of its weight a server starts its slow start with
+	DefaultSlowStartMinWeightPercent = 10.0
+)
+
+type HealthCheckType string
+
+const (
+	HealthCheckHTTP   HealthCheckType = "http"
+	HealthCheckTCP    HealthCheckType = "tcp"
+	HealthCheckGRPC   HealthCheckType = "grpc"
+	HealthCheckTLS    HealthCheckType = "tls"
+	HealthCheckScript HealthCheckType = "script"
+)
+
+const (
+	DefaultHealthCheckPath     = "/health"
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultHealthCheckInterval = 30 * time.Second
+	// Successful probes in a row before an unhealthy server is marked as healthy
+	DefaultHealthyThreshold = 2
+	// Failed probes in a row before a healthy server is marked as unhealthy
+	DefaultUnhealthyThreshold = 3
+)
+
+// Number of points each server gets on a hash ring, scaled by its weight
+const DefaultVirtualNodes = 160
+
+// HashKeySource is the part of the request that hash based strategies hash
+type HashKeySource string
+
+const (
+	ClientIPHashKey HashKeySource = "client-ip"
+	HeaderHashKey   HashKeySource = "header"
+	CookieHashKey   HashKeySource = "cookie"
+	QueryHashKey    HashKeySource = "query"
+	PathHashKey     HashKeySource = "path"
+)
+
+// HashKeyFallback decides what is hashed when the request doesn't carry the hash key
+type HashKeyFallback string
+
+const (
+	ClientIPFallback HashKeyFallback = "client-ip"
+	RandomFallback   HashKeyFallback = "random"
 )
 
-// Dont forget to update this list when adding a new strategy
-var Strategies = []Strategy{
-	RoundRobin,
-	Random,
-	WeightedRoundRobin,
-	IPHashing,
-	LeastConnections,
-	LeastResponseTime,
+const DefaultStickySessionCookieName = "tlb_session"
+
+// Size of the maglev lookup table, must be a prime much larger than the number of servers
+const DefaultMaglevTableSize = 65537
+
+const (
+	// Request bodies up to this size are kept in memory, so they can be resent on retries
+	DefaultMaxRetryBodyMemory int64 = 1 << 20
+	// Request bodies up to this size are spilled to a temporary file, bigger ones are not retried
+	DefaultMaxRetryBodySize int64 = 10 << 20
+)
+
+type RetryError string
+
+const (
+	RetryOnConnectFailure RetryError = "connect-failure"
+	RetryOnReset          RetryError = "reset"
+	RetryOnTimeout        RetryError = "timeout"
+)
+
+var RetryErrors = []RetryError{
+	RetryOnConnectFailure,
+	RetryOnReset,
+	RetryOnTimeout,
 }
+
+// Methods that are safe to replay on another server
+var DefaultRetryMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+
+var DefaultRetryStatusCodes = []int{500, 502, 503, 504}
+
+const (
+	// Retries are capped at this ratio of the requests seen in the budget window
+	DefaultRetryBudgetRatio = 0.2
+	// Retries that are always allowed in the budget window, regardless of traffic
+	DefaultRetryBudgetMinRetries = 10
+	DefaultRetryBudgetWindow     = 10 * time.Second
+	// Base interval of the exponential backoff between attempts, the max interval defaults to 10 times this
+	DefaultRetryBackoffBaseInterval = 25 * time.Millisecond
+)
+
+const (
+	// Failed responses in a row before outlier detection ejects a server
+	DefaultConsecutive5xx           = 5
+	DefaultConsecutiveGatewayErrors = 5
+	// How often success rates are compared and ejection multipliers wind down
+	DefaultOutlierDetectionInterval = 10 * time.Second
+	// Every repeat ejection lasts one more base ejection time, up to the max
+	DefaultBaseEjectionTime = 30 * time.Second
+	DefaultMaxEjectionTime  = 300 * time.Second
+	// No more servers are ejected once this share of the pool is out
+	DefaultMaxEjectionPercent = 10
+	// Success rates are only compared between this many servers that saw enough requests
+	DefaultSuccessRateMinimumHosts  = 5
+	DefaultSuccessRateRequestVolume = 100
+	DefaultSuccessRateStdevFactor   = 1.9
+)
+
+const (
+	// Failed requests in a row that trip a circuit breaker
+	DefaultBreakerConsecutiveFailures = 5
+	// Error rate over the window that trips a circuit breaker, once the window has enough requests
+	DefaultBreakerErrorRatePercent = 50.0
+	DefaultBreakerMinRequests      = 20
+	DefaultBreakerWindow           = 10 * time.Second
+	// How long an open circuit breaker rejects requests before trial requests are let through
+	DefaultBreakerCooldown         = 30 * time.Second
+	DefaultBreakerHalfOpenRequests = 3
+)
+
+// How long a webhook may take to accept an event
+const DefaultWebhookTimeout = 5 * time.Second
+
+// The admin API only listens on localhost unless another host is configured
+const DefaultAdminHost = "127.0.0.1"
+
+// How long a draining server may take to finish its requests before the drain is reported as done anyway
+const DefaultDrainTimeout = 5 * time.Minute
+
+// How long in-flight requests may take to finish on shutdown
+const DefaultShutdownGracePeriod = 30 * time.Second
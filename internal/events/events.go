@@ -0,0 +1,134 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	ServerUp   Type = "server-up"
+	ServerDown Type = "server-down"
+	// ServerEjected is emitted when outlier detection takes a server out of the pool
+	ServerEjected Type = "server-ejected"
+	// ServerDrained is emitted when a draining server has no requests left or its drain timeout expired
+	ServerDrained  Type = "server-drained"
+	ConfigReloaded Type = "config-reloaded"
+	// PoolEmpty is emitted when the last healthy server goes down
+	PoolEmpty Type = "pool-empty"
+	// EjectionSkipped is emitted when outlier detection keeps a failing server, because it is the
+	// last available one or too many servers are out already
+	EjectionSkipped Type = "ejection-skipped"
+)
+
+var Types = []Type{
+	ServerUp,
+	ServerDown,
+	ServerEjected,
+	ServerDrained,
+	ConfigReloaded,
+	PoolEmpty,
+	EjectionSkipped,
+}
+
+type Event struct {
+	Type   Type      `json:"type"`
+	Server string    `json:"server,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Subscriber gets every event emitted on the bus it is subscribed to.
+// Notify is called synchronously, so it must not block.
+type Subscriber interface {
+	Notify(e Event)
+}
+
+// SubscriberFunc turns a function into a Subscriber
+type SubscriberFunc func(e Event)
+
+func (f SubscriberFunc) Notify(e Event) {
+	f(e)
+}
+
+// Bus hands the events emitted by the load balancer and the health checks to its subscribers.
+// A nil bus drops every event, so components can emit without checking for one.
+type Bus struct {
+	mut         sync.Mutex
+	subscribers map[int]Subscriber
+	nextID      int
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]Subscriber{}}
+}
+
+// Subscribe adds a subscriber, the returned function removes it again
+func (b *Bus) Subscribe(s Subscriber) func() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = s
+
+	return func() {
+		b.mut.Lock()
+		defer b.mut.Unlock()
+
+		delete(b.subscribers, id)
+	}
+}
+
+// Channel subscribes a channel with the given buffer. Events are dropped while the buffer is full,
+// so a slow reader can't hold up the load balancer. The returned function unsubscribes and closes the channel.
+func (b *Bus) Channel(buffer int) (<-chan Event, func()) {
+	var mut sync.Mutex
+	closed := false
+	ch := make(chan Event, buffer)
+	unsubscribe := b.Subscribe(SubscriberFunc(func(e Event) {
+		mut.Lock()
+		defer mut.Unlock()
+
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}))
+
+	return ch, func() {
+		unsubscribe()
+		mut.Lock()
+		defer mut.Unlock()
+
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+// Emit hands the event to every subscriber, the time defaults to now
+func (b *Bus) Emit(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mut.Lock()
+	subscribers := make([]Subscriber, 0, len(b.subscribers))
+	for _, s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mut.Unlock()
+
+	for _, s := range subscribers {
+		s.Notify(e)
+	}
+}
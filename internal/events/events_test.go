@@ -0,0 +1,48 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	received := []Event{}
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(e Event) {
+		received = append(received, e)
+	}))
+
+	bus.Emit(Event{Type: ServerDown, Server: "http://localhost:8081"})
+	unsubscribe()
+	bus.Emit(Event{Type: ServerUp, Server: "http://localhost:8081"})
+
+	if len(received) != 1 || received[0].Type != ServerDown {
+		t.Fatalf("Expected only the event before unsubscribing, got %+v", received)
+	}
+	if received[0].Time.IsZero() {
+		t.Fatalf("Expected the event time to be set")
+	}
+
+	// A nil bus drops events
+	var nilBus *Bus
+	nilBus.Emit(Event{Type: PoolEmpty})
+}
+
+func TestBusChannel(t *testing.T) {
+	bus := NewBus()
+	ch, unsubscribe := bus.Channel(2)
+
+	// Events that don't fit in the buffer are dropped instead of blocking
+	bus.Emit(Event{Type: ServerDown})
+	bus.Emit(Event{Type: PoolEmpty})
+	bus.Emit(Event{Type: ServerUp})
+	unsubscribe()
+	bus.Emit(Event{Type: ServerDown})
+
+	received := []Type{}
+	for e := range ch {
+		received = append(received, e.Type)
+	}
+	if len(received) != 2 || received[0] != ServerDown || received[1] != PoolEmpty {
+		t.Fatalf("Expected the first 2 events, got %v", received)
+	}
+}
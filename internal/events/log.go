@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogSubscriber writes every event to a structured log, events about servers
+// going away are warnings and the others are info
+type LogSubscriber struct {
+	Logger *slog.Logger
+}
+
+func (l *LogSubscriber) Notify(e Event) {
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level := slog.LevelInfo
+	if e.Type == ServerDown || e.Type == ServerEjected || e.Type == PoolEmpty {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{{
+		Key:   "type",
+		Value: slog.StringValue(string(e.Type)),
+	}}
+	if e.Server != "" {
+		attrs = append(attrs, slog.Attr{
+			Key:   "Server",
+			Value: slog.StringValue(e.Server),
+		})
+	}
+	if e.Reason != "" {
+		attrs = append(attrs, slog.Attr{
+			Key:   "reason",
+			Value: slog.StringValue(e.Reason),
+		})
+	}
+	logger.LogAttrs(context.Background(), level, "Event", attrs...)
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// Events waiting to be sent to a webhook, newer events are dropped once the queue is full
+const webhookQueueSize = 100
+
+// Webhook POSTs events as JSON to a URL. Events are queued and sent one at a time in the background,
+// in the order they were emitted, so a slow endpoint never holds up the load balancer.
+type Webhook struct {
+	URL     string
+	Headers http.Header
+	// Types are the events sent to the webhook, all of them when it is empty
+	Types   []Type
+	Timeout time.Duration
+	Client  *http.Client
+	Logger  *slog.Logger
+	mut     sync.Mutex
+	queue   chan Event
+	done    chan struct{}
+	closed  bool
+}
+
+func (w *Webhook) Notify(e Event) {
+	if len(w.Types) > 0 && !slices.Contains(w.Types, e.Type) {
+		return
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if w.closed {
+		return
+	}
+	if w.queue == nil {
+		w.queue = make(chan Event, webhookQueueSize)
+		w.done = make(chan struct{})
+		go w.run(w.queue, w.done)
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.getLogger().Warn("Webhook queue is full, dropping event", slog.Attr{
+			Key:   "url",
+			Value: slog.StringValue(w.URL),
+		}, slog.Attr{
+			Key:   "type",
+			Value: slog.StringValue(string(e.Type)),
+		})
+	}
+}
+
+// Close sends the events that are still queued and stops the webhook, later events are dropped
+func (w *Webhook) Close() {
+	w.mut.Lock()
+	queue, done := w.queue, w.done
+	alreadyClosed := w.closed
+	w.closed = true
+	w.mut.Unlock()
+
+	if queue == nil || alreadyClosed {
+		return
+	}
+	close(queue)
+	<-done
+}
+
+func (w *Webhook) run(queue <-chan Event, done chan<- struct{}) {
+	defer close(done)
+
+	for e := range queue {
+		if err := w.send(e); err != nil {
+			w.getLogger().Warn("Error sending event to webhook", slog.Attr{
+				Key:   "url",
+				Value: slog.StringValue(w.URL),
+			}, slog.Attr{
+				Key:   "type",
+				Value: slog.StringValue(string(e.Type)),
+			}, slog.Attr{
+				Key:   "error",
+				Value: slog.StringValue(err.Error()),
+			})
+		}
+	}
+}
+
+func (w *Webhook) send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range w.Headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (w *Webhook) getLogger() *slog.Logger {
+	if w.Logger != nil {
+		return w.Logger
+	}
+
+	return slog.Default()
+}
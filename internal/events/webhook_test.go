@@ -0,0 +1,53 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var mut sync.Mutex
+	received := []Event{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mut.Lock()
+		received = append(received, e)
+		mut.Unlock()
+	}))
+	defer ts.Close()
+
+	webhook := &Webhook{
+		URL:     ts.URL,
+		Headers: http.Header{"Authorization": {"Bearer token"}},
+		Types:   []Type{ServerDown, ServerUp},
+	}
+	bus := NewBus()
+	bus.Subscribe(webhook)
+	bus.Emit(Event{Type: ServerDown, Server: "http://localhost:8081", Reason: "connection refused"})
+	bus.Emit(Event{Type: ServerEjected, Server: "http://localhost:8082"})
+	bus.Emit(Event{Type: ServerUp, Server: "http://localhost:8081"})
+
+	// Close sends what is still queued
+	webhook.Close()
+	bus.Emit(Event{Type: ServerDown, Server: "http://localhost:8081"})
+
+	mut.Lock()
+	defer mut.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", received)
+	}
+	if received[0].Type != ServerDown || received[0].Reason != "connection refused" || received[1].Type != ServerUp {
+		t.Fatalf("Expected the down and up events in order, got %+v", received)
+	}
+}
@@ -0,0 +1,181 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// Health check responses are only read up to this size when matching the body
+const maxBodySize = 64 << 10
+
+// StatusRange is an inclusive range of status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// Check describes how a server is probed. Zero values fall back to the defaults from the constants package.
+// Path, Method, Host, Headers, ExpectedStatuses and the body matchers belong to http checks,
+// the other types have their own fields.
+type Check struct {
+	Type    constants.HealthCheckType
+	Path    string
+	Method  string
+	Host    string
+	Headers http.Header
+	// ExpectedStatuses are the status codes of a healthy server, 2xx and 3xx by default
+	ExpectedStatuses []StatusRange
+	// Body must be contained in the response body when it is set
+	Body string
+	// BodyRegex must match the response body when it is set
+	BodyRegex *regexp.Regexp
+	// Send is written to the connection of tcp checks, and Expect must be part of the answer
+	Send   string
+	Expect string
+	// Service is the service grpc checks ask about, the whole server when it is empty
+	Service string
+	// MinCertValidity is how long the certificate of tls checks must stay valid
+	MinCertValidity time.Duration
+	// InsecureSkipVerify skips verifying the certificate chain of tls and grpc checks
+	InsecureSkipVerify bool
+	// Command is run by script checks, the server is healthy when it exits with 0
+	Command []string
+	Timeout time.Duration
+}
+
+// Run probes the server, it returns an error describing why the server is unhealthy
+func (c Check) Run(ctx context.Context, client *http.Client, serverURL *url.URL) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch c.Type {
+	case constants.HealthCheckTCP:
+		return c.runTCP(ctx, serverURL)
+	case constants.HealthCheckGRPC:
+		return c.runGRPC(ctx, serverURL)
+	case constants.HealthCheckTLS:
+		return c.runTLS(ctx, serverURL)
+	case constants.HealthCheckScript:
+		return c.runScript(ctx, serverURL)
+	}
+
+	return c.runHTTP(ctx, client, serverURL)
+}
+
+func (c Check) runHTTP(ctx context.Context, client *http.Client, serverURL *url.URL) error {
+	req, err := http.NewRequestWithContext(ctx, c.getMethod(), serverURL.JoinPath(c.getPath()).String(), nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.Headers {
+		req.Header[name] = values
+	}
+	if c.Host != "" {
+		req.Host = c.Host
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if !c.isExpectedStatus(res.StatusCode) {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	if c.Body == "" && c.BodyRegex == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if c.Body != "" && !strings.Contains(string(body), c.Body) {
+		return fmt.Errorf("response body doesn't contain %q", c.Body)
+	}
+	if c.BodyRegex != nil && !c.BodyRegex.Match(body) {
+		return fmt.Errorf("response body doesn't match %s", c.BodyRegex)
+	}
+
+	return nil
+}
+
+// getAddress returns the host and port of the server, the port defaults to the one of the scheme
+func getAddress(serverURL *url.URL) string {
+	if serverURL.Port() != "" {
+		return serverURL.Host
+	}
+	port := "80"
+	if serverURL.Scheme == "https" {
+		port = "443"
+	}
+
+	return net.JoinHostPort(serverURL.Hostname(), port)
+}
+
+func (c Check) getMethod() string {
+	if c.Method != "" {
+		return c.Method
+	}
+
+	return http.MethodGet
+}
+
+func (c Check) getPath() string {
+	if c.Path != "" {
+		return c.Path
+	}
+
+	return constants.DefaultHealthCheckPath
+}
+
+func (c Check) isExpectedStatus(statusCode int) bool {
+	expectedStatuses := c.ExpectedStatuses
+	if len(expectedStatuses) == 0 {
+		expectedStatuses = []StatusRange{{Min: 200, Max: 399}}
+	}
+	for _, r := range expectedStatuses {
+		if statusCode >= r.Min && statusCode <= r.Max {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseStatusRange parses a single status code like "200" or a range like "200-299"
+func ParseStatusRange(s string) (StatusRange, error) {
+	minValue, maxValue, isRange := strings.Cut(s, "-")
+	if !isRange {
+		maxValue = minValue
+	}
+
+	min, err := strconv.Atoi(strings.TrimSpace(minValue))
+	if err != nil {
+		return StatusRange{}, fmt.Errorf("invalid status range %s", s)
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(maxValue))
+	if err != nil {
+		return StatusRange{}, fmt.Errorf("invalid status range %s", s)
+	}
+	if min < 100 || max > 599 || min > max {
+		return StatusRange{}, fmt.Errorf("invalid status range %s", s)
+	}
+
+	return StatusRange{Min: min, Max: max}, nil
+}
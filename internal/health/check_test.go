@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) *url.URL {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	serverURL, _ := url.Parse(ts.URL)
+
+	return serverURL
+}
+
+func TestCheckStatus(t *testing.T) {
+	testCases := []struct {
+		id               int
+		status           int
+		expectedStatuses []StatusRange
+		healthy          bool
+	}{
+		{id: 1, status: http.StatusOK, healthy: true},
+		{id: 2, status: http.StatusNoContent, healthy: true},
+		{id: 3, status: http.StatusFound, healthy: true},
+		{id: 4, status: http.StatusNotFound, healthy: false},
+		{id: 5, status: http.StatusInternalServerError, healthy: false},
+		{id: 6, status: http.StatusFound, expectedStatuses: []StatusRange{{Min: 200, Max: 299}}, healthy: false},
+		{id: 7, status: http.StatusTeapot, expectedStatuses: []StatusRange{{Min: 200, Max: 200}, {Min: 418, Max: 418}}, healthy: true},
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, tc := range testCases {
+		serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if tc.status == http.StatusFound {
+				w.Header().Set("Location", "/elsewhere")
+			}
+			w.WriteHeader(tc.status)
+		})
+		check := Check{ExpectedStatuses: tc.expectedStatuses}
+
+		err := check.Run(context.Background(), client, serverURL)
+		if tc.healthy && err != nil {
+			t.Fatalf("Test case %d: Expected server to be healthy, got %s", tc.id, err)
+		}
+		if !tc.healthy && err == nil {
+			t.Fatalf("Test case %d: Expected server to be unhealthy", tc.id)
+		}
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	var req *http.Request
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	})
+	check := Check{
+		Path:    "/ready",
+		Method:  http.MethodHead,
+		Host:    "backend.internal",
+		Headers: http.Header{"Authorization": []string{"Bearer token"}},
+	}
+
+	err := check.Run(context.Background(), http.DefaultClient, serverURL)
+	if err != nil {
+		t.Fatalf("Expected server to be healthy, got %s", err)
+	}
+	if req.URL.Path != "/ready" || req.Method != http.MethodHead || req.Host != "backend.internal" {
+		t.Fatalf("Expected HEAD backend.internal/ready, got %s %s%s", req.Method, req.Host, req.URL.Path)
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("Expected custom header to be sent, got %v", req.Header)
+	}
+
+	// The default check is a GET to /health
+	Check{}.Run(context.Background(), http.DefaultClient, serverURL)
+	if req.URL.Path != "/health" || req.Method != http.MethodGet {
+		t.Fatalf("Expected GET /health, got %s %s", req.Method, req.URL.Path)
+	}
+}
+
+func TestCheckBody(t *testing.T) {
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok","version":"1.2.3"}`))
+	})
+
+	testCases := []struct {
+		id      int
+		check   Check
+		healthy bool
+	}{
+		{id: 1, check: Check{Body: `"status":"ok"`}, healthy: true},
+		{id: 2, check: Check{Body: `"status":"degraded"`}, healthy: false},
+		{id: 3, check: Check{BodyRegex: regexp.MustCompile(`"version":"1\.\d+\.\d+"`)}, healthy: true},
+		{id: 4, check: Check{BodyRegex: regexp.MustCompile(`"version":"2\.`)}, healthy: false},
+	}
+
+	for _, tc := range testCases {
+		err := tc.check.Run(context.Background(), http.DefaultClient, serverURL)
+		if tc.healthy && err != nil {
+			t.Fatalf("Test case %d: Expected server to be healthy, got %s", tc.id, err)
+		}
+		if !tc.healthy && err == nil {
+			t.Fatalf("Test case %d: Expected server to be unhealthy", tc.id)
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	check := Check{Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	err := check.Run(context.Background(), http.DefaultClient, serverURL)
+	if err == nil {
+		t.Fatalf("Expected slow server to be unhealthy")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected check to time out after 50ms, took %s", elapsed)
+	}
+}
+
+func TestParseStatusRange(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected StatusRange
+		valid    bool
+	}{
+		{input: "200", expected: StatusRange{Min: 200, Max: 200}, valid: true},
+		{input: "200-299", expected: StatusRange{Min: 200, Max: 299}, valid: true},
+		{input: " 200 - 399 ", expected: StatusRange{Min: 200, Max: 399}, valid: true},
+		{input: "299-200", valid: false},
+		{input: "99", valid: false},
+		{input: "200-600", valid: false},
+		{input: "2xx", valid: false},
+	}
+
+	for _, tc := range testCases {
+		statusRange, err := ParseStatusRange(tc.input)
+		if tc.valid && (err != nil || statusRange != tc.expected) {
+			t.Fatalf("Expected %s to parse as %v, got %v, %v", tc.input, tc.expected, statusRange, err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("Expected %s to be invalid", tc.input)
+		}
+	}
+}
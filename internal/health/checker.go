@@ -0,0 +1,215 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+// Target is a server and how it is probed. Zero values fall back to the defaults from the constants package.
+type Target struct {
+	Server   *server.Server
+	Check    Check
+	Interval time.Duration
+	// UnhealthyInterval is the time between probes while the server is unhealthy, half the interval by default
+	UnhealthyInterval time.Duration
+	// Jitter is the random delay added to every probe, a tenth of the interval by default
+	Jitter time.Duration
+	// HealthyThreshold is the number of successful probes in a row that mark the server as healthy
+	HealthyThreshold int
+	// UnhealthyThreshold is the number of failed probes in a row that mark the server as unhealthy
+	UnhealthyThreshold int
+}
+
+// Checker probes its targets in the background, from Start until Stop is called or the context is done.
+// Each target is probed on its own schedule, starting at a random offset, so probes are spread out.
+type Checker struct {
+	Targets []Target
+	Client  *http.Client
+	Logger  *slog.Logger
+	Events  *events.Bus
+	mut     sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (c *Checker) Start(ctx context.Context) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.cancel != nil {
+		return
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	for _, target := range c.Targets {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.run(ctx, target)
+		}()
+	}
+}
+
+// Stop stops probing and waits for the probes in flight to finish
+func (c *Checker) Stop() {
+	c.mut.Lock()
+	cancel := c.cancel
+	c.cancel = nil
+	c.mut.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	c.wg.Wait()
+}
+
+func (c *Checker) run(ctx context.Context, target Target) {
+	interval := target.getInterval()
+	offset := interval
+	if !target.Server.IsHealthy() {
+		offset = target.getUnhealthyInterval()
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(offset))))
+	defer timer.Stop()
+
+	successes, failures := 0, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		err := target.Check.Run(ctx, c.getClient(), target.Server.URL)
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := target.Server.IsHealthy()
+		if err != nil {
+			successes = 0
+			failures++
+			if healthy && failures >= target.getUnhealthyThreshold() {
+				c.getLogger().Warn("Server is not healthy", slog.Attr{
+					Key:   "Server",
+					Value: slog.StringValue(target.Server.URL.String()),
+				}, slog.Attr{
+					Key:   "error",
+					Value: slog.StringValue(err.Error()),
+				}, slog.Attr{
+					Key:   "failures",
+					Value: slog.IntValue(failures),
+				})
+				target.Server.SetHealthy(false)
+				healthy = false
+				c.Events.Emit(events.Event{
+					Type:   events.ServerDown,
+					Server: target.Server.URL.String(),
+					Reason: err.Error(),
+				})
+				if !c.hasHealthyServers() {
+					c.Events.Emit(events.Event{Type: events.PoolEmpty})
+				}
+			}
+		} else {
+			failures = 0
+			successes++
+			if !healthy && successes >= target.getHealthyThreshold() {
+				c.getLogger().Info("Server is healthy", slog.Attr{
+					Key:   "Server",
+					Value: slog.StringValue(target.Server.URL.String()),
+				}, slog.Attr{
+					Key:   "successes",
+					Value: slog.IntValue(successes),
+				})
+				target.Server.SetHealthy(true)
+				healthy = true
+				c.Events.Emit(events.Event{
+					Type:   events.ServerUp,
+					Server: target.Server.URL.String(),
+				})
+			}
+		}
+
+		next := interval
+		if !healthy {
+			next = target.getUnhealthyInterval()
+		}
+		if jitter := target.getJitter(); jitter > 0 {
+			next += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		timer.Reset(next)
+	}
+}
+
+func (c *Checker) hasHealthyServers() bool {
+	for _, target := range c.Targets {
+		if target.Server.IsHealthy() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Checker) getClient() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+
+	return http.DefaultClient
+}
+
+func (c *Checker) getLogger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+
+	return slog.Default()
+}
+
+func (t Target) getInterval() time.Duration {
+	if t.Interval > 0 {
+		return t.Interval
+	}
+
+	return constants.DefaultHealthCheckInterval
+}
+
+func (t Target) getUnhealthyInterval() time.Duration {
+	if t.UnhealthyInterval > 0 {
+		return t.UnhealthyInterval
+	}
+
+	return t.getInterval() / 2
+}
+
+func (t Target) getJitter() time.Duration {
+	if t.Jitter > 0 {
+		return t.Jitter
+	}
+
+	return t.getInterval() / 10
+}
+
+func (t Target) getHealthyThreshold() int {
+	if t.HealthyThreshold > 0 {
+		return t.HealthyThreshold
+	}
+
+	return constants.DefaultHealthyThreshold
+}
+
+func (t Target) getUnhealthyThreshold() int {
+	if t.UnhealthyThreshold > 0 {
+		return t.UnhealthyThreshold
+	}
+
+	return constants.DefaultUnhealthyThreshold
+}
@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func waitFor(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCheckerThresholds(t *testing.T) {
+	var status, probes atomic.Int32
+	status.Store(http.StatusOK)
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(int(status.Load()))
+	})
+	s := server.NewServer(serverURL, 1)
+	checker := &Checker{
+		Targets: []Target{{
+			Server:             s,
+			Interval:           10 * time.Millisecond,
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 3,
+		}},
+	}
+	checker.Start(context.Background())
+	defer checker.Stop()
+
+	// It takes three failed probes in a row to mark the server as unhealthy
+	status.Store(http.StatusInternalServerError)
+	start := probes.Load()
+	waitFor(t, func() bool { return !s.IsHealthy() }, "Expected server to become unhealthy")
+	if failed := probes.Load() - start; failed < 3 {
+		t.Fatalf("Expected at least 3 probes before the server is unhealthy, got %d", failed)
+	}
+
+	// And two successful ones to bring it back
+	status.Store(http.StatusOK)
+	start = probes.Load()
+	waitFor(t, func() bool { return s.IsHealthy() }, "Expected server to become healthy")
+	if succeeded := probes.Load() - start; succeeded < 2 {
+		t.Fatalf("Expected at least 2 probes before the server is healthy, got %d", succeeded)
+	}
+}
+
+func TestCheckerEvents(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	})
+	s := server.NewServer(serverURL, 1)
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Channel(10)
+	checker := &Checker{
+		Targets: []Target{{
+			Server:             s,
+			Interval:           5 * time.Millisecond,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		}},
+		Events: bus,
+	}
+	checker.Start(context.Background())
+
+	// Events are only emitted when the server changes state, not on every probe
+	status.Store(http.StatusInternalServerError)
+	waitFor(t, func() bool { return !s.IsHealthy() }, "Expected server to become unhealthy")
+	time.Sleep(50 * time.Millisecond)
+	status.Store(http.StatusOK)
+	waitFor(t, func() bool { return s.IsHealthy() }, "Expected server to become healthy")
+	time.Sleep(50 * time.Millisecond)
+	checker.Stop()
+	unsubscribe()
+
+	received := []events.Type{}
+	for e := range ch {
+		received = append(received, e.Type)
+	}
+	expected := []events.Type{events.ServerDown, events.PoolEmpty, events.ServerUp}
+	if !slices.Equal(received, expected) {
+		t.Fatalf("Expected events %v, got %v", expected, received)
+	}
+}
+
+func TestCheckerUnhealthyInterval(t *testing.T) {
+	var probes atomic.Int32
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	s := server.NewServer(serverURL, 1)
+	s.SetHealthy(false)
+	checker := &Checker{
+		Targets: []Target{{
+			Server:            s,
+			Interval:          time.Hour,
+			UnhealthyInterval: 10 * time.Millisecond,
+			Jitter:            time.Millisecond,
+		}},
+	}
+	checker.Start(context.Background())
+	defer checker.Stop()
+
+	// While the server is unhealthy it is probed every 10ms instead of every hour
+	time.Sleep(100 * time.Millisecond)
+	if probes.Load() < 3 {
+		t.Fatalf("Expected the unhealthy server to be probed often, got %d probes", probes.Load())
+	}
+}
+
+func TestCheckerStop(t *testing.T) {
+	var probes atomic.Int32
+	serverURL := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	checker := &Checker{
+		Targets: []Target{{Server: server.NewServer(serverURL, 1), Interval: 5 * time.Millisecond}},
+	}
+	checker.Start(ctx)
+	waitFor(t, func() bool { return probes.Load() > 0 }, "Expected the server to be probed")
+
+	// Cancelling the context stops the probes, Stop waits for them to finish
+	cancel()
+	checker.Stop()
+	// A probe that was cancelled on its way may still reach the server
+	time.Sleep(20 * time.Millisecond)
+	stopped := probes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if probes.Load() != stopped {
+		t.Fatalf("Expected no probes after the checker stopped, got %d more", probes.Load()-stopped)
+	}
+}
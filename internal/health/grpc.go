@@ -0,0 +1,133 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// Values of grpc.health.v1.HealthCheckResponse.ServingStatus
+var grpcServingStatuses = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// runGRPC calls the standard grpc.health.v1 Health/Check method, the server is healthy when it
+// answers SERVING. The messages only have a single field, so they are encoded by hand.
+// Servers with an https URL are called over TLS, the others over plain HTTP/2.
+func (c Check) runGRPC(ctx context.Context, serverURL *url.URL) error {
+	transport := &http.Transport{
+		Protocols:       new(http.Protocols),
+		TLSClientConfig: c.getTLSConfig(serverURL),
+	}
+	if serverURL.Scheme == "https" {
+		transport.Protocols.SetHTTP2(true)
+	} else {
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	defer transport.CloseIdleConnections()
+
+	// HealthCheckRequest has the service name as field 1
+	var message []byte
+	if c.Service != "" {
+		message = append([]byte{0x0a}, binary.AppendUvarint(nil, uint64(len(c.Service)))...)
+		message = append(message, c.Service...)
+	}
+	frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(message)))
+	frame = append(frame, message...)
+
+	callURL := *serverURL
+	callURL.Path = grpcHealthCheckPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callURL.String(), bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if c.Host != "" {
+		req.Host = c.Host
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	// Errors without a message come in the headers, the others in the trailers
+	grpcStatus, grpcMessage := res.Header.Get("Grpc-Status"), res.Header.Get("Grpc-Message")
+	if grpcStatus == "" {
+		grpcStatus, grpcMessage = res.Trailer.Get("Grpc-Status"), res.Trailer.Get("Grpc-Message")
+	}
+	if grpcStatus != "0" {
+		return fmt.Errorf("grpc status %s %s", grpcStatus, grpcMessage)
+	}
+
+	if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		return fmt.Errorf("invalid grpc response")
+	}
+	status, err := getGRPCServingStatus(body[5:])
+	if err != nil {
+		return err
+	}
+	if status != 1 {
+		name, ok := grpcServingStatuses[status]
+		if !ok {
+			name = strconv.FormatUint(status, 10)
+		}
+
+		return fmt.Errorf("grpc service is %s", name)
+	}
+
+	return nil
+}
+
+// getGRPCServingStatus reads field 1 of a HealthCheckResponse, other fields are skipped
+func getGRPCServingStatus(message []byte) (uint64, error) {
+	status := uint64(0)
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid grpc response")
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, fmt.Errorf("invalid grpc response")
+			}
+			message = message[n:]
+			if key>>3 == 1 {
+				status = value
+			}
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return 0, fmt.Errorf("invalid grpc response")
+			}
+			message = message[n+int(length):]
+		default:
+			return 0, fmt.Errorf("invalid grpc response")
+		}
+	}
+
+	return status, nil
+}
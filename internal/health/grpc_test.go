@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// newGRPCHealthServer answers Health/Check over plain HTTP/2 with the status of the requested service
+func newGRPCHealthServer(t *testing.T, statuses map[string]uint64) *url.URL {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		service := ""
+		if len(body) > 7 {
+			service = string(body[7:])
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		status, ok := statuses[service]
+		if !ok {
+			// NOT_FOUND without a message is sent in the headers
+			w.Header().Set("Grpc-Status", "5")
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		message := []byte{0x08, byte(status)}
+		w.Write(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(message))))
+		w.Write(message)
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	serverURL, _ := url.Parse(ts.URL)
+
+	return serverURL
+}
+
+func TestCheckGRPC(t *testing.T) {
+	serverURL := newGRPCHealthServer(t, map[string]uint64{"": 1, "payments": 1, "search": 2})
+	testCases := []struct {
+		id      int
+		service string
+		healthy bool
+	}{
+		{id: 1, service: "", healthy: true},
+		{id: 2, service: "payments", healthy: true},
+		{id: 3, service: "search", healthy: false},
+		{id: 4, service: "unknown", healthy: false},
+	}
+
+	for _, tc := range testCases {
+		check := Check{Type: constants.HealthCheckGRPC, Service: tc.service}
+		err := check.Run(context.Background(), nil, serverURL)
+		if tc.healthy != (err == nil) {
+			t.Fatalf("Test case %d: Expected server to be healthy: %t, got %v", tc.id, tc.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// runScript runs Command with the URL of the server in TLB_SERVER_URL, the server is healthy when it exits with 0
+func (c Check) runScript(ctx context.Context, serverURL *url.URL) error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("no command to run")
+	}
+
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = append(os.Environ(), "TLB_SERVER_URL="+serverURL.String())
+	// Don't wait forever for children of a killed command that keep its output open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if len(message) > 256 {
+			message = message[:256]
+		}
+		if message == "" {
+			return err
+		}
+
+		return fmt.Errorf("%w: %s", err, message)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestCheckScript(t *testing.T) {
+	serverURL, _ := url.Parse("http://localhost:8081")
+	testCases := []struct {
+		id      int
+		command []string
+		healthy bool
+	}{
+		{id: 1, command: []string{"sh", "-c", "exit 0"}, healthy: true},
+		{id: 2, command: []string{"sh", "-c", "echo down; exit 1"}, healthy: false},
+		{id: 3, command: []string{"sh", "-c", `test "$TLB_SERVER_URL" = http://localhost:8081`}, healthy: true},
+		{id: 4, command: nil, healthy: false},
+	}
+
+	for _, tc := range testCases {
+		check := Check{Type: constants.HealthCheckScript, Command: tc.command}
+		err := check.Run(context.Background(), nil, serverURL)
+		if tc.healthy != (err == nil) {
+			t.Fatalf("Test case %d: Expected server to be healthy: %t, got %v", tc.id, tc.healthy, err)
+		}
+	}
+
+	// The output of the command ends up in the error
+	check := Check{Type: constants.HealthCheckScript, Command: []string{"sh", "-c", "echo disk full; exit 2"}}
+	err := check.Run(context.Background(), nil, serverURL)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Expected error with the command output, got %v", err)
+	}
+}
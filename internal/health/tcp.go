@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// runTCP connects to the server, then writes Send and waits for Expect when they are set
+func (c Check) runTCP(ctx context.Context, serverURL *url.URL) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", getAddress(serverURL))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if c.Send != "" {
+		if _, err := io.WriteString(conn, c.Send); err != nil {
+			return err
+		}
+	}
+	if c.Expect == "" {
+		return nil
+	}
+
+	// Read until the expected answer shows up, the server closes the connection or the timeout is hit
+	var answer strings.Builder
+	buf := make([]byte, 4096)
+	for answer.Len() < maxBodySize {
+		n, err := conn.Read(buf)
+		answer.Write(buf[:n])
+		if strings.Contains(answer.String(), c.Expect) {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("answer doesn't contain %q", c.Expect)
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestCheckTCP(t *testing.T) {
+	// Answers PONG to PING, and closes the connection on anything else
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				if line == "PING\n" {
+					conn.Write([]byte("+PONG\r\n"))
+				}
+			}()
+		}
+	}()
+	serverURL, _ := url.Parse("tcp://" + listener.Addr().String())
+
+	closedListener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedURL, _ := url.Parse("tcp://" + closedListener.Addr().String())
+	closedListener.Close()
+
+	testCases := []struct {
+		id        int
+		serverURL *url.URL
+		check     Check
+		healthy   bool
+	}{
+		{id: 1, serverURL: serverURL, check: Check{}, healthy: true},
+		{id: 2, serverURL: serverURL, check: Check{Send: "PING\n", Expect: "PONG"}, healthy: true},
+		{id: 3, serverURL: serverURL, check: Check{Send: "QUIT\n", Expect: "PONG"}, healthy: false},
+		{id: 4, serverURL: closedURL, check: Check{}, healthy: false},
+	}
+
+	for _, tc := range testCases {
+		tc.check.Type = constants.HealthCheckTCP
+		tc.check.Timeout = time.Second
+		err := tc.check.Run(context.Background(), nil, tc.serverURL)
+		if tc.healthy != (err == nil) {
+			t.Fatalf("Test case %d: Expected server to be healthy: %t, got %v", tc.id, tc.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// runTLS completes a handshake with the server and checks that its certificate
+// stays valid for at least MinCertValidity
+func (c Check) runTLS(ctx context.Context, serverURL *url.URL) error {
+	dialer := tls.Dialer{Config: c.getTLSConfig(serverURL)}
+	conn, err := dialer.DialContext(ctx, "tcp", getAddress(serverURL))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+	// Certificates are checked even when verification is skipped, an expired one is always a failure
+	notAfter := certificates[0].NotAfter
+	if time.Until(notAfter) < c.MinCertValidity || time.Now().After(notAfter) {
+		return fmt.Errorf("certificate expires at %s", notAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (c Check) getTLSConfig(serverURL *url.URL) *tls.Config {
+	serverName := serverURL.Hostname()
+	if c.Host != "" {
+		serverName = c.Host
+	}
+
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestCheckTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(ts.Close)
+	serverURL, _ := url.Parse(ts.URL)
+	notAfter := ts.Certificate().NotAfter
+
+	testCases := []struct {
+		id      int
+		check   Check
+		healthy bool
+	}{
+		// The test certificate is self signed
+		{id: 1, check: Check{}, healthy: false},
+		{id: 2, check: Check{InsecureSkipVerify: true}, healthy: true},
+		{id: 3, check: Check{InsecureSkipVerify: true, MinCertValidity: time.Until(notAfter) - time.Hour}, healthy: true},
+		{id: 4, check: Check{InsecureSkipVerify: true, MinCertValidity: time.Until(notAfter) + time.Hour}, healthy: false},
+	}
+
+	for _, tc := range testCases {
+		tc.check.Type = constants.HealthCheckTLS
+		err := tc.check.Run(context.Background(), nil, serverURL)
+		if tc.healthy != (err == nil) {
+			t.Fatalf("Test case %d: Expected server to be healthy: %t, got %v", tc.id, tc.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// replayableBody keeps a copy of the request body, so it can be resent on every retry.
+// Small bodies are kept in memory, larger ones are spilled to a temporary file.
+// Bodies bigger than the max size are only partially buffered and can be read once.
+type replayableBody struct {
+	buf  []byte
+	file *os.File
+	size int64
+	rest io.ReadCloser
+}
+
+func newReplayableBody(body io.ReadCloser, memoryLimit int64, maxSize int64) (*replayableBody, error) {
+	rb := &replayableBody{}
+
+	buf, err := io.ReadAll(io.LimitReader(body, memoryLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	rb.buf = buf
+	rb.size = int64(len(buf))
+	if rb.size <= memoryLimit {
+		body.Close()
+		return rb, nil
+	}
+
+	if maxSize <= memoryLimit {
+		rb.rest = body
+		return rb, nil
+	}
+
+	file, err := os.CreateTemp("", "tiny-loadbalancer-body-*")
+	if err != nil {
+		return nil, err
+	}
+	rb.file = file
+	rb.buf = nil
+	if _, err = file.Write(buf); err != nil {
+		rb.Close()
+		return nil, err
+	}
+	copied, err := io.Copy(file, io.LimitReader(body, maxSize-rb.size+1))
+	if err != nil {
+		rb.Close()
+		return nil, err
+	}
+	rb.size += copied
+	if rb.size > maxSize {
+		rb.rest = body
+		return rb, nil
+	}
+
+	body.Close()
+	return rb, nil
+}
+
+// Replayable reports whether the whole body was buffered and can be sent more than once
+func (rb *replayableBody) Replayable() bool {
+	return rb.rest == nil
+}
+
+// Size returns the number of buffered bytes
+func (rb *replayableBody) Size() int64 {
+	return rb.size
+}
+
+// NewReader returns a reader positioned at the start of the body
+func (rb *replayableBody) NewReader() io.ReadCloser {
+	var buffered io.Reader
+	if rb.file != nil {
+		buffered = io.NewSectionReader(rb.file, 0, rb.size)
+	} else {
+		buffered = bytes.NewReader(rb.buf)
+	}
+
+	if rb.rest != nil {
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(buffered, rb.rest), rb.rest}
+	}
+
+	return io.NopCloser(buffered)
+}
+
+func (rb *replayableBody) Close() error {
+	if rb.rest != nil {
+		rb.rest.Close()
+	}
+	if rb.file != nil {
+		rb.file.Close()
+		return os.Remove(rb.file.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package loadbalancer
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+// watchDrain reports the drain of the server once its last request finished, or when the drain
+// timeout expires with requests still running. It must be called with tlb.Mut held.
+func (tlb *TinyLoadBalancer) watchDrain(s *server.Server) {
+	timeout := cmp.Or(tlb.DrainTimeout, constants.DefaultDrainTimeout)
+	bus := tlb.Events
+
+	s.Mut.Lock()
+	since, activeConnections := s.DrainingSince, s.ActiveConnections
+	s.Mut.Unlock()
+
+	slog.Default().Info("Draining server", slog.Attr{
+		Key:   "Server",
+		Value: slog.StringValue(s.URL.String()),
+	}, slog.Attr{
+		Key:   "activeConnections",
+		Value: slog.IntValue(activeConnections),
+	}, slog.Attr{
+		Key:   "timeout",
+		Value: slog.DurationValue(timeout),
+	})
+	if activeConnections == 0 {
+		reportDrained(bus, s, since, "no active connections")
+		return
+	}
+
+	time.AfterFunc(timeout, func() {
+		s.Mut.Lock()
+		activeConnections := s.ActiveConnections
+		s.Mut.Unlock()
+		reportDrained(bus, s, since, fmt.Sprintf("drain timeout expired with %d active connections", activeConnections))
+	})
+}
+
+// reportDrained emits ServerDrained, unless the drain that started at since was reported already or is over
+func reportDrained(bus *events.Bus, s *server.Server, since time.Time, reason string) {
+	if !s.MarkDrained(since) {
+		return
+	}
+
+	bus.Emit(events.Event{
+		Type:   events.ServerDrained,
+		Server: s.URL.String(),
+		Reason: reason,
+	})
+}
@@ -1,114 +1,132 @@
 package loadbalancer
 
 import (
-	"errors"
-	"hash/fnv"
-	"io"
+	"cmp"
+	"context"
 	"log/slog"
-	"math"
 	"math/rand"
 	"net/http"
-	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
 	"github.com/tiny-loadbalancer/internal/server"
+	"github.com/tiny-loadbalancer/internal/strategy"
 )
 
 type TinyLoadBalancer struct {
-	Servers       []*server.Server
-	Port          int
-	Mut           sync.Mutex
-	NextServer    int
-	Strategy      constants.Strategy
-	RetryRequests bool
+	Servers            []*server.Server
+	Port               int
+	Mut                sync.Mutex
+	Strategy           constants.Strategy
+	StrategyOptions    strategy.Options
+	RetryRequests      bool
+	MaxRetryBodyMemory int64
+	MaxRetryBodySize   int64
+	RetryPolicy        RetryPolicy
+	OutlierDetection   OutlierDetection
+	// DrainTimeout is how long draining servers may take to finish their requests
+	DrainTimeout    time.Duration
+	Events          *events.Bus
+	retryBudget     *RetryBudget
+	outlierDetector *outlierDetector
+	strategy        strategy.Strategy
 }
 
 func (tlb *TinyLoadBalancer) GetRequestHandler() http.HandlerFunc {
-	switch tlb.Strategy {
-	case constants.RoundRobin:
+	s, err := strategy.New(tlb.Strategy, tlb.StrategyOptions)
+	if err != nil {
 		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerRoundRobin)
-		}
-	case constants.Random:
-		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerRandom)
-		}
-	case constants.WeightedRoundRobin:
-		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerWeightedRoundRobin)
-		}
-	case constants.IPHashing:
-		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerIPHashing)
-		}
-	case constants.LeastConnections:
-		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerLeastConnections)
-		}
-	case constants.LeastResponseTime:
-		return func(w http.ResponseWriter, r *http.Request) {
-			tlb.requestHandler(w, r, tlb.getNextServerLeastResponseTime)
+			http.Error(w, "Strategy not supported", http.StatusBadRequest)
 		}
+	}
 
-	default:
-		return func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, "Strategy not supported", http.StatusBadRequest)
+	tlb.Mut.Lock()
+	tlb.strategy = s
+	for _, server := range tlb.Servers {
+		if server.IsDraining() {
+			tlb.watchDrain(server)
 		}
 	}
+	tlb.Mut.Unlock()
+
+	return tlb.requestHandler
 }
 
-func (tlb *TinyLoadBalancer) requestHandler(
-	w http.ResponseWriter,
-	r *http.Request,
-	getNextServer func(ip string) (*server.Server, error),
-) {
+func (tlb *TinyLoadBalancer) requestHandler(w http.ResponseWriter, r *http.Request) {
 	logger := slog.Default()
 	var err error
 	tlb.Mut.Lock()
 	shouldRetryRequests := tlb.RetryRequests
-	serversCount := len(tlb.Servers)
+	pool := tlb.Servers
+	serversCount := len(pool)
+	currentStrategy := tlb.strategy
+	maxRetryBodyMemory := tlb.MaxRetryBodyMemory
+	maxRetryBodySize := tlb.MaxRetryBodySize
+	retryPolicy := tlb.RetryPolicy
+	retryBudget := tlb.getRetryBudget()
+	outlierDetector := tlb.getOutlierDetector()
+	bus := tlb.Events
 	tlb.Mut.Unlock()
+	retryBudget.RecordRequest()
+
+	// Keep a copy of the body, so every attempt sends the same payload
+	var body *replayableBody
+	if shouldRetryRequests && retryPolicy.AllowsRequest(r) && r.Body != nil && r.Body != http.NoBody {
+		if maxRetryBodyMemory <= 0 {
+			maxRetryBodyMemory = constants.DefaultMaxRetryBodyMemory
+		}
+		if maxRetryBodySize <= 0 {
+			maxRetryBodySize = constants.DefaultMaxRetryBodySize
+		}
+		body, err = newReplayableBody(r.Body, maxRetryBodyMemory, maxRetryBodySize)
+		if err != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
 
-	for i := 0; i < serversCount; i++ {
+		// Bodies that are too large to buffer can only be sent once
+		if !body.Replayable() {
+			logger.Info("Request body is too large to retry", slog.Attr{
+				Key:   "Path",
+				Value: slog.StringValue(r.URL.Path),
+			})
+			shouldRetryRequests = false
+		}
+	}
+
+	canRetry := shouldRetryRequests && retryPolicy.AllowsRequest(r)
+	maxAttempts := retryPolicy.GetMaxAttempts(serversCount)
+	tried := make([]*server.Server, 0, maxAttempts)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		// Retries go to servers that weren't tried yet, unless every server was
 		var server *server.Server
-		server, err = getNextServer(r.RemoteAddr)
+		server, err = tlb.getServer(strategy.WithExcluded(r, tried...), currentStrategy, pool)
+		if err != nil && len(tried) > 0 {
+			server, err = tlb.getServer(r, currentStrategy, pool)
+		}
 		if err != nil {
 			http.Error(w, "No healthy servers", http.StatusServiceUnavailable)
 			return
 		}
 
-		// Make the request to the server
-		proxy := server.GetReverseProxy()
-		rec := httptest.NewRecorder()
-		server.Mut.Lock()
-		server.ActiveConnections++
-		server.Mut.Unlock()
-		start := time.Now()
-		logger.Info("Sending request to server", slog.Attr{
-			Key:   "Server",
-			Value: slog.StringValue(server.URL.String()),
-		}, slog.Attr{
-			Key:   "Method",
-			Value: slog.StringValue(r.Method),
-		}, slog.Attr{
-			Key:   "Path",
-			Value: slog.StringValue(r.URL.Path),
-		}, slog.Attr{
-			Key:   "RemoteAddr",
-			Value: slog.StringValue(r.RemoteAddr),
+		rw, transportErr, budgetExhausted, elapsed := tlb.serveAttempt(w, r, body, server, attempt, attemptOptions{
+			isLast:          !canRetry || attempt == maxAttempts,
+			strategy:        currentStrategy,
+			pool:            pool,
+			retryPolicy:     retryPolicy,
+			retryBudget:     retryBudget,
+			outlierDetector: outlierDetector,
+			bus:             bus,
 		})
-		proxy.ServeHTTP(rec, r)
-		elapsed := time.Since(start)
+		tried = append(tried, server)
 
-		// Update server statistics
-		tlb.updateServerStats(server, elapsed)
-
-		// If the response was OK, return the response, otherwise for loop continues and tries with the next server
-		// This ensures fault tolerance and hides single server failures from the client
-		if rec.Code < http.StatusInternalServerError {
-			logger.Info("Sending response from server", slog.Attr{
+		// Unless the response was dropped for a retry, it has already been streamed to the client
+		if !rw.Discarded() {
+			logger.Info("Sent response from server", slog.Attr{
 				Key:   "Server",
 				Value: slog.StringValue(server.URL.String()),
 			}, slog.Attr{
@@ -116,222 +134,330 @@ func (tlb *TinyLoadBalancer) requestHandler(
 				Value: slog.DurationValue(elapsed),
 			}, slog.Attr{
 				Key:   "status",
-				Value: slog.IntValue(rec.Code),
+				Value: slog.IntValue(rw.StatusCode()),
 			})
-			tlb.returnResponse(rec, w)
-			server.Mut.Lock()
-			server.ActiveConnections--
-			server.Mut.Unlock()
-			return
-		}
-
-		// If we don't want to retry requests, just return the response
-		if !shouldRetryRequests {
-			tlb.returnResponse(rec, w)
-			tlb.setServerAsDead(server)
+			if budgetExhausted {
+				logger.Warn("Retry budget exhausted, not retrying request", slog.Attr{
+					Key:   "Server",
+					Value: slog.StringValue(server.URL.String()),
+				}, slog.Attr{
+					Key:   "budget",
+					Value: slog.AnyValue(retryBudget.Stats()),
+				})
+			}
 			return
 		}
 
-		logger.Info("Server", server.URL.String(), "returned status", slog.Attr{
+		// Otherwise the loop continues and tries with the next server.
+		// This ensures fault tolerance and hides single server failures from the client
+		backoff := retryPolicy.GetBackoff(attempt)
+		logger.Info("Retrying failed request", slog.Attr{
+			Key:   "Server",
+			Value: slog.StringValue(server.URL.String()),
+		}, slog.Attr{
 			Key:   "status",
-			Value: slog.IntValue(rec.Code),
+			Value: slog.IntValue(rw.StatusCode()),
+		}, slog.Attr{
+			Key:   "error",
+			Value: slog.AnyValue(transportErr),
+		}, slog.Attr{
+			Key:   "backoff",
+			Value: slog.DurationValue(backoff),
+		}, slog.Attr{
+			Key:   "budget",
+			Value: slog.AnyValue(retryBudget.Stats()),
 		})
-		tlb.setServerAsDead(server)
+
+		// Wait before the next attempt, unless the client is gone and there is nobody to retry for
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return
+		}
 	}
 
 	http.Error(w, "No healthy servers", http.StatusServiceUnavailable)
 }
 
-func (tlb *TinyLoadBalancer) updateServerStats(server *server.Server, elapsed time.Duration) {
-	server.Mut.Lock()
-	server.RequestsCount++
-	server.RequestsDuration += elapsed
-	server.Mut.Unlock()
+// attemptOptions are the settings of the request that every attempt shares
+type attemptOptions struct {
+	isLast          bool
+	strategy        strategy.Strategy
+	pool            []*server.Server
+	retryPolicy     RetryPolicy
+	retryBudget     *RetryBudget
+	outlierDetector *outlierDetector
+	bus             *events.Bus
 }
 
-func (tlb *TinyLoadBalancer) setServerAsDead(server *server.Server) {
-	server.Mut.Lock()
-	server.CurrentWeight = 0
-	server.ActiveConnections = 0
-	server.Healthy = false
-	server.RequestsCount = 0
-	server.RequestsDuration = 0
-	server.Mut.Unlock()
-}
+// serveAttempt sends the request to the server. The response is streamed to the client unless
+// it is a failure that is going to be retried on the next server.
+// The bookkeeping of the attempt is deferred, so it also runs when the proxy aborts the handler
+// because the backend or the client broke off a streamed response.
+func (tlb *TinyLoadBalancer) serveAttempt(w http.ResponseWriter, r *http.Request, body *replayableBody, server *server.Server, attempt int, opts attemptOptions) (rw *streamingResponseWriter, transportErr error, budgetExhausted bool, elapsed time.Duration) {
+	var timedOut atomic.Bool
+	rw = newStreamingResponseWriter(w, func(code int) bool {
+		if opts.isLast || !opts.retryPolicy.AllowsResponse(code, transportErr) {
+			return false
+		}
+		if !opts.retryBudget.TryRetry() {
+			budgetExhausted = true
+			return false
+		}
 
-func (tlb *TinyLoadBalancer) returnResponse(rec *httptest.ResponseRecorder, w http.ResponseWriter) {
-	for k, v := range rec.Header() {
-		w.Header()[k] = v
+		return true
+	})
+	proxy := server.GetReverseProxy()
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if timedOut.Load() {
+			err = context.DeadlineExceeded
+		}
+		transportErr = err
+		if kind, _ := getRetryError(err); kind == constants.RetryOnTimeout {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
 	}
-	w.WriteHeader(rec.Code)
-	io.Copy(w, rec.Body)
-}
-
-func (tlb *TinyLoadBalancer) getNextServerRoundRobin(_ string) (*server.Server, error) {
-	tlb.Mut.Lock()
-	defer tlb.Mut.Unlock()
-
-	server := tlb.Servers[tlb.NextServer]
-	if !server.Healthy {
-		for i := 0; i < len(tlb.Servers)-1; i++ {
-			tlb.incrementNextServer()
-			server = tlb.Servers[tlb.NextServer]
-			if server.Healthy {
-				break
-			}
+	ctx, cancel := context.WithCancel(r.Context())
+	var timer *time.Timer
+	if opts.retryPolicy.PerTryTimeout > 0 {
+		// The per try timeout only covers the time until the response is committed,
+		// so long running downloads and streams are not cut off
+		timer = time.AfterFunc(opts.retryPolicy.PerTryTimeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		rw.onCommit = func() {
+			timer.Stop()
 		}
+	}
 
-		if !server.Healthy {
-			return nil, errors.New("No healthy servers")
+	server.Mut.Lock()
+	server.ActiveConnections++
+	server.Mut.Unlock()
+	if hook, ok := opts.strategy.(strategy.ResponseHeaderHook); ok {
+		rw.onHeader = func(header http.Header) {
+			hook.OnResponseHeader(r, server, header)
 		}
 	}
+	if hook, ok := opts.strategy.(strategy.RequestStartHook); ok {
+		hook.OnRequestStart(server)
+	}
+	start := time.Now()
+	defer func() {
+		// A response that broke off midway panics with http.ErrAbortHandler, it counts as a
+		// failed attempt and the panic goes on to the server, which closes the connection
+		aborted := recover()
+		if timer != nil {
+			timer.Stop()
+		}
+		cancel()
+		elapsed = time.Since(start)
+		server.Mut.Lock()
+		server.ActiveConnections--
+		drained := server.Draining && server.ActiveConnections == 0
+		drainingSince := server.DrainingSince
+		server.Mut.Unlock()
+		if drained {
+			reportDrained(opts.bus, server, drainingSince, "no active connections")
+		}
 
-	tlb.incrementNextServer()
+		// Update server statistics. A client that went away is not the server's fault,
+		// unless the per try timeout cancelled the request
+		clientGone := r.Context().Err() != nil && !timedOut.Load()
+		server.UpdateStats(elapsed)
+		switch {
+		case clientGone:
+			server.ReleaseRequest()
+		case aborted != nil:
+			opts.outlierDetector.Record(opts.pool, server, http.StatusBadGateway)
+			server.RecordResult(false)
+		default:
+			opts.outlierDetector.Record(opts.pool, server, rw.StatusCode())
+			server.RecordResult(rw.StatusCode() < http.StatusInternalServerError)
+		}
+		if hook, ok := opts.strategy.(strategy.RequestFinishHook); ok {
+			hook.OnRequestFinish(server, elapsed)
+		}
 
-	return server, nil
+		if aborted != nil {
+			slog.Default().Warn("Response from server broke off", slog.Attr{
+				Key:   "Server",
+				Value: slog.StringValue(server.URL.String()),
+			}, slog.Attr{
+				Key:   "status",
+				Value: slog.IntValue(rw.StatusCode()),
+			})
+			panic(aborted)
+		}
+	}()
+
+	slog.Default().Info("Sending request to server", slog.Attr{
+		Key:   "Server",
+		Value: slog.StringValue(server.URL.String()),
+	}, slog.Attr{
+		Key:   "Method",
+		Value: slog.StringValue(r.Method),
+	}, slog.Attr{
+		Key:   "Path",
+		Value: slog.StringValue(r.URL.Path),
+	}, slog.Attr{
+		Key:   "RemoteAddr",
+		Value: slog.StringValue(r.RemoteAddr),
+	}, slog.Attr{
+		Key:   "Attempt",
+		Value: slog.IntValue(attempt),
+	})
+	proxy.ServeHTTP(rw, tlb.getAttemptRequest(r.WithContext(ctx), body))
+
+	// elapsed is set by the deferred bookkeeping
+	return
 }
 
-func (tlb *TinyLoadBalancer) incrementNextServer() {
-	tlb.NextServer++
-	if tlb.NextServer >= len(tlb.Servers) {
-		tlb.NextServer = 0
-	}
+type ServerStats struct {
+	URL               string              `json:"url"`
+	Healthy           bool                `json:"healthy"`
+	Disabled          bool                `json:"disabled"`
+	Draining          bool                `json:"draining"`
+	Drained           bool                `json:"drained"`
+	Ejected           bool                `json:"ejected"`
+	CircuitBreaker    server.BreakerState `json:"circuitBreaker"`
+	Weight            int                 `json:"weight"`
+	EffectiveWeight   float64             `json:"effectiveWeight"`
+	ActiveConnections int                 `json:"activeConnections"`
+	RequestsCount     int64               `json:"requestsCount"`
 }
 
-func (tlb *TinyLoadBalancer) getNextServerRandom(_ string) (*server.Server, error) {
-	tlb.Mut.Lock()
-	defer tlb.Mut.Unlock()
+type Stats struct {
+	Strategy      constants.Strategy `json:"strategy"`
+	RetryRequests bool               `json:"retryRequests"`
+	Servers       []ServerStats      `json:"servers"`
+	RetryBudget   RetryBudgetStats   `json:"retryBudget"`
+}
 
-	healthyServers := make([]*server.Server, 0)
-	for _, s := range tlb.Servers {
-		if s.Healthy {
-			healthyServers = append(healthyServers, s)
-		}
+// Stats returns a snapshot of the load balancer and its servers
+func (tlb *TinyLoadBalancer) Stats() Stats {
+	tlb.Mut.Lock()
+	stats := Stats{
+		Strategy:      tlb.Strategy,
+		RetryRequests: tlb.RetryRequests,
+		Servers:       make([]ServerStats, 0, len(tlb.Servers)),
+		RetryBudget:   tlb.getRetryBudget().Stats(),
 	}
+	servers := tlb.Servers
+	tlb.Mut.Unlock()
 
-	if len(healthyServers) == 0 {
-		return nil, errors.New("No healthy servers")
+	for _, s := range servers {
+		effectiveWeight, ejected, breakerState, drained := s.EffectiveWeight(), s.IsEjected(), s.GetBreakerState(), s.IsDrained()
+		s.Mut.Lock()
+		stats.Servers = append(stats.Servers, ServerStats{
+			URL:               s.URL.String(),
+			Healthy:           s.Healthy,
+			Disabled:          s.Disabled,
+			Draining:          s.Draining,
+			Drained:           drained,
+			Ejected:           ejected,
+			CircuitBreaker:    breakerState,
+			Weight:            s.Weight,
+			EffectiveWeight:   effectiveWeight,
+			ActiveConnections: s.ActiveConnections,
+			RequestsCount:     s.RequestsCount,
+		})
+		s.Mut.Unlock()
 	}
 
-	max := len(healthyServers)
-	idx := rand.Intn(max)
-
-	return healthyServers[idx], nil
+	return stats
 }
 
-func (tlb *TinyLoadBalancer) getNextServerWeightedRoundRobin(_ string) (*server.Server, error) {
+// GetServers returns the current pool, a reload replaces it instead of changing it
+func (tlb *TinyLoadBalancer) GetServers() []*server.Server {
 	tlb.Mut.Lock()
 	defer tlb.Mut.Unlock()
 
-	server := tlb.Servers[tlb.NextServer]
-	if !server.Healthy || server.CurrentWeight == 0 {
-		healthyServersCount := 0
-		for i := 0; i < len(tlb.Servers)-1; i++ {
-			tlb.incrementNextServer()
-			server = tlb.Servers[tlb.NextServer]
-			if server.Healthy {
-				healthyServersCount++
-			}
-			if server.Healthy && server.CurrentWeight > 0 {
-				break
-			}
-		}
-
-		if healthyServersCount == 0 {
-			return nil, errors.New("No healthy servers")
-		}
+	return tlb.Servers
+}
 
-		if server.CurrentWeight == 0 {
-			tlb.resetServerWeights()
-		}
+// getRetryBudget must be called with tlb.Mut held
+func (tlb *TinyLoadBalancer) getRetryBudget() *RetryBudget {
+	if tlb.retryBudget == nil {
+		tlb.retryBudget = tlb.RetryPolicy.NewRetryBudget()
 	}
 
-	server.CurrentWeight--
-	tlb.incrementNextServer()
-
-	return server, nil
+	return tlb.retryBudget
 }
 
-func (tlb *TinyLoadBalancer) resetServerWeights() {
-	for _, s := range tlb.Servers {
-		s.CurrentWeight = s.Weight
+// getOutlierDetector must be called with tlb.Mut held
+func (tlb *TinyLoadBalancer) getOutlierDetector() *outlierDetector {
+	if tlb.outlierDetector == nil {
+		tlb.outlierDetector = newOutlierDetector(tlb.OutlierDetection, tlb.Events)
 	}
-}
 
-func (tlb *TinyLoadBalancer) getNextServerIPHashing(ip string) (*server.Server, error) {
-	tlb.Mut.Lock()
-	defer tlb.Mut.Unlock()
-
-	hash := fnv.New32a()
-	hash.Write([]byte(ip))
-	hashedIP := hash.Sum32()
+	return tlb.outlierDetector
+}
 
-	idx := int(hashedIP) % len(tlb.Servers)
-	server := tlb.Servers[idx]
+// getServer asks the strategy for the next server. A server in slow start only keeps the part of
+// its picks that matches its ramp, the rest are handed to the strategy's next choice, so the
+// ramp works the same for every strategy. Clients pinned to the server by the strategy are not
+// held back, so they keep their session. Servers whose circuit breaker has no trial request
+// left are skipped the same way.
+func (tlb *TinyLoadBalancer) getServer(r *http.Request, s strategy.Strategy, pool []*server.Server) (*server.Server, error) {
+	var first *server.Server
+	affinity, _ := s.(strategy.AffinityStrategy)
+	// Only looked up once a server in slow start is picked, so pools without slow start aren't scanned
+	warm, checkedWarm := false, false
+	for range pool {
+		candidate, err := s.Next(r, pool)
+		if err != nil {
+			break
+		}
+		r = strategy.WithExcluded(r, candidate)
 
-	if !server.Healthy {
-		for i := 0; i < len(tlb.Servers)-1; i++ {
-			idx++
-			if idx >= len(tlb.Servers) {
-				idx = 0
+		factor := candidate.GetSlowStartFactor()
+		if factor < 1 && rand.Float64() >= factor && (affinity == nil || !affinity.IsPinned(r, candidate)) {
+			if !checkedWarm {
+				warm, checkedWarm = hasWarmServers(pool), true
 			}
-			server = tlb.Servers[idx]
-			if server.Healthy {
-				break
+			if warm {
+				first = cmp.Or(first, candidate)
+				continue
 			}
 		}
-
-		if !server.Healthy {
-			return nil, errors.New("No healthy servers")
+		if candidate.AllowRequest() {
+			return candidate, nil
 		}
 	}
 
-	return server, nil
-}
+	// Every pick was held back by slow start, so the first one gets the request anyway
+	if first != nil && first.AllowRequest() {
+		return first, nil
+	}
 
-func (tlb *TinyLoadBalancer) getNextServerLeastConnections(_ string) (*server.Server, error) {
-	tlb.Mut.Lock()
-	defer tlb.Mut.Unlock()
+	return nil, strategy.ErrNoHealthyServers
+}
 
-	minActiveConnections := math.MaxInt32
-	idx := -1
-	for i := 0; i < len(tlb.Servers); i++ {
-		if tlb.Servers[i].ActiveConnections < minActiveConnections && tlb.Servers[i].Healthy {
-			minActiveConnections = tlb.Servers[i].ActiveConnections
-			idx = i
+// hasWarmServers reports whether a healthy server is done with slow start,
+// when all of them are ramping up there is nobody to hand their traffic to
+func hasWarmServers(pool []*server.Server) bool {
+	for _, s := range pool {
+		if s.IsAvailable() && s.GetSlowStartFactor() >= 1 {
+			return true
 		}
 	}
-	if idx == -1 {
-		return nil, errors.New("No healthy servers")
-	}
 
-	return tlb.Servers[idx], nil
+	return false
 }
 
-func (tlb *TinyLoadBalancer) getNextServerLeastResponseTime(_ string) (*server.Server, error) {
-	tlb.Mut.Lock()
-	defer tlb.Mut.Unlock()
-
-	leastResponseTime := int64(math.MaxInt64)
-	leastResponseTimeServer := -1
-	for i := 0; i < len(tlb.Servers); i++ {
-		if !tlb.Servers[i].Healthy {
-			continue
-		}
-		if tlb.Servers[i].RequestsCount == 0 {
-			return tlb.Servers[i], nil
-		}
-
-		avgResponseTime := int64(tlb.Servers[i].RequestsDuration) / tlb.Servers[i].RequestsCount
-		if avgResponseTime < leastResponseTime {
-			leastResponseTime = avgResponseTime
-			leastResponseTimeServer = i
-		}
+func (tlb *TinyLoadBalancer) getAttemptRequest(r *http.Request, body *replayableBody) *http.Request {
+	if body == nil {
+		return r
 	}
 
-	if leastResponseTimeServer == -1 {
-		return nil, errors.New("No healthy servers")
+	req := r.Clone(r.Context())
+	req.Body = body.NewReader()
+	if body.Replayable() {
+		req.ContentLength = body.Size()
 	}
 
-	return tlb.Servers[leastResponseTimeServer], nil
+	return req
 }
@@ -1,485 +1,1054 @@
 package loadbalancer
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
 	"github.com/tiny-loadbalancer/internal/server"
+	"github.com/tiny-loadbalancer/internal/strategy"
 )
 
-var ip = "127.0.0.1"
+func TestRequestHandlerStreamsResponse(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("data: second\n\n"))
+	}))
+	defer backend.Close()
 
-func TestRoundRobinGetNextServer(t *testing.T) {
+	backendURL, _ := url.Parse(backend.URL)
 	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			{
-				URL:     &url.URL{Host: "localhost:8080"},
-				Healthy: true,
-			},
-			{
-				URL:     &url.URL{Host: "localhost:8081"},
-				Healthy: true,
-			},
-		},
-		NextServer: 0,
+		Servers:       []*server.Server{server.NewServer(backendURL, 0)},
+		Strategy:      constants.RoundRobin,
+		RetryRequests: true,
 	}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
+	defer close(release)
 
-	server, err := tlb.getNextServerRoundRobin(ip)
+	res, err := http.Get(lb.URL)
 	if err != nil {
-		t.Fatalf("Error getting next server: %s", err.Error())
+		t.Fatalf("Error making request: %s", err.Error())
 	}
-
-	if server.URL.Host != "localhost:8080" {
-		t.Fatalf("Expected server to be localhost:8080, got %s", server.URL.Host)
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected backend headers to be forwarded, got %v", res.Header)
 	}
 
-	server, err = tlb.getNextServerRoundRobin(ip)
-	if err != nil {
-		t.Fatalf("Error getting next server: %s", err.Error())
+	// The first event must arrive while the backend is still holding the response open
+	buf := make([]byte, len("data: first\n\n"))
+	if _, err := io.ReadFull(res.Body, buf); err != nil {
+		t.Fatalf("Error reading first event: %s", err.Error())
 	}
+	if string(buf) != "data: first\n\n" {
+		t.Fatalf("Expected first event, got %q", buf)
+	}
+}
+
+func TestRequestHandlerForwardsTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("body"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer backend.Close()
 
-	if server.URL.Host != "localhost:8081" {
-		t.Fatalf("Expected server to be localhost:8081, got %s", server.URL.Host)
+	backendURL, _ := url.Parse(backend.URL)
+	tlb := &TinyLoadBalancer{
+		Servers:  []*server.Server{server.NewServer(backendURL, 0)},
+		Strategy: constants.RoundRobin,
 	}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
 
-	server, err = tlb.getNextServerRoundRobin(ip)
+	res, err := http.Get(lb.URL)
 	if err != nil {
-		t.Fatalf("Error getting next server: %s", err.Error())
+		t.Fatalf("Error making request: %s", err.Error())
 	}
-
-	if server.URL.Host != "localhost:8080" {
-		t.Fatalf("Expected server to be localhost:8080, got %s", server.URL.Host)
+	defer res.Body.Close()
+	// Trailers are only known once the body was read
+	io.Copy(io.Discard, res.Body)
+	if res.Trailer.Get("X-Checksum") != "abc" {
+		t.Fatalf("Expected the trailer of the backend, got %v", res.Trailer)
 	}
 }
 
-func TestRoundRobinNextServerNoHealthyServers(t *testing.T) {
+// newAbortingBackend answers with part of the body it announced and closes the connection,
+// until recovered is set
+func newAbortingBackend(t *testing.T, recovered *atomic.Bool) *url.URL {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recovered != nil && recovered.Load() {
+			w.Write([]byte("OK"))
+			return
+		}
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Error hijacking connection: %s", err.Error())
+			return
+		}
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\npartial")
+		buf.Flush()
+		conn.Close()
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+
+	return backendURL
+}
+
+func TestRequestHandlerAbortedResponse(t *testing.T) {
+	s := server.NewServer(newAbortingBackend(t, nil), 0)
 	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			{
-				URL:     &url.URL{Host: "localhost:8080"},
-				Healthy: false,
-			},
-			{
-				URL:     &url.URL{Host: "localhost:8081"},
-				Healthy: false,
-			},
-		},
-		NextServer: 0,
+		Servers:     []*server.Server{s},
+		Strategy:    constants.RoundRobin,
+		RetryPolicy: RetryPolicy{PerTryTimeout: time.Minute},
+	}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
+
+	for i := 0; i < 3; i++ {
+		res, err := http.Get(lb.URL)
+		if err != nil {
+			t.Fatalf("Error making request: %s", err.Error())
+		}
+		if _, err := io.ReadAll(res.Body); err == nil {
+			t.Fatalf("Expected the broken off response to reach the client as an error")
+		}
+		res.Body.Close()
 	}
 
-	_, err := tlb.getNextServerRoundRobin(ip)
-	if err == nil {
-		t.Fatalf("Expected error getting next server")
+	// The connection to the client is closed after the bookkeeping of the attempt
+	s.Mut.Lock()
+	activeConnections, requestsCount := s.ActiveConnections, s.RequestsCount
+	s.Mut.Unlock()
+	if activeConnections != 0 || requestsCount != 3 {
+		t.Fatalf("Expected aborted requests to finish, got %d active and %d counted", activeConnections, requestsCount)
 	}
 }
 
-func TestRoundRobinNextServerOneUnhealthyServer(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			{
-				URL:     &url.URL{Host: "localhost:8080"},
-				Healthy: false,
-			},
-			{
-				URL:     &url.URL{Host: "localhost:8081"},
-				Healthy: true,
-			},
-		},
-		NextServer: 0,
+func TestRequestHandlerAbortedTrialRequest(t *testing.T) {
+	var recovered atomic.Bool
+	s := server.NewServer(newAbortingBackend(t, &recovered), 0)
+	s.CircuitBreaker = server.CircuitBreaker{Enabled: true, ConsecutiveFailures: 1, Cooldown: 20 * time.Millisecond, HalfOpenRequests: 1}
+	tlb := &TinyLoadBalancer{Servers: []*server.Server{s}, Strategy: constants.RoundRobin}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
+
+	// The breaker opens on a failed request
+	s.AllowRequest()
+	s.RecordResult(false)
+
+	// Every trial request of the half-open breaker breaks off
+	for i := 0; i < 3; i++ {
+		time.Sleep(30 * time.Millisecond)
+		if res, err := http.Get(lb.URL); err == nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
 	}
 
-	server, err := tlb.getNextServerRoundRobin(ip)
+	recovered.Store(true)
+	time.Sleep(30 * time.Millisecond)
+	res, err := http.Get(lb.URL)
 	if err != nil {
-		t.Fatalf("Error getting next server: %s", err.Error())
+		t.Fatalf("Error making request: %s", err.Error())
 	}
-	if server.URL.Host != "localhost:8081" {
-		t.Fatalf("Expected server to be localhost:8081, got %s", server.URL.Host)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "OK" || s.GetBreakerState() != server.BreakerClosed {
+		t.Fatalf("Expected the server to come back after broken off trials, got %q and a %s breaker", body, s.GetBreakerState())
 	}
 }
 
-func TestWeightedRoundRobinNextserverNoHealthyServers(t *testing.T) {
+func TestRequestHandlerRetriesUncommittedServerError(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failing", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	failingURL, _ := url.Parse(failing.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
 	tlb := &TinyLoadBalancer{
 		Servers: []*server.Server{
-			{
-				URL:     &url.URL{Host: "localhost:8080"},
-				Healthy: false,
-				Weight:  0,
-			},
-			{
-				URL:     &url.URL{Host: "localhost:8081"},
-				Healthy: false,
-				Weight:  0,
-			},
+			server.NewServer(failingURL, 0),
+			server.NewServer(healthyURL, 0),
 		},
-		NextServer: 0,
+		Strategy:      constants.RoundRobin,
+		RetryRequests: true,
+	}
+	rec := httptest.NewRecorder()
+	tlb.GetRequestHandler()(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != "healthy" {
+		t.Fatalf("Expected body from healthy server, got %q", rec.Body.String())
+	}
+	// A single failure is not enough for outlier detection to eject the server
+	if !tlb.Servers[0].IsAvailable() {
+		t.Fatalf("Expected failing server to stay available after one failure")
+	}
+}
+
+func TestRequestHandlerReplaysBodyOnRetry(t *testing.T) {
+	testCases := []struct {
+		name               string
+		maxRetryBodyMemory int64
+		maxRetryBodySize   int64
+		expectedStatus     int
+	}{
+		{name: "in memory", maxRetryBodyMemory: 1024, maxRetryBodySize: 2048, expectedStatus: http.StatusOK},
+		{name: "spilled to file", maxRetryBodyMemory: 4, maxRetryBodySize: 2048, expectedStatus: http.StatusOK},
+		{name: "too large", maxRetryBodyMemory: 4, maxRetryBodySize: 8, expectedStatus: http.StatusInternalServerError},
 	}
+	payload := "the same payload on every attempt"
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				http.Error(w, "failing", http.StatusInternalServerError)
+			}))
+			defer failing.Close()
+			echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body)
+			}))
+			defer echo.Close()
 
-	_, err := tlb.getNextServerWeightedRoundRobin(ip)
-	if err == nil {
-		t.Fatalf("Expected error getting next server")
+			failingURL, _ := url.Parse(failing.URL)
+			echoURL, _ := url.Parse(echo.URL)
+			tlb := &TinyLoadBalancer{
+				Servers: []*server.Server{
+					server.NewServer(failingURL, 0),
+					server.NewServer(echoURL, 0),
+				},
+				Strategy:           constants.RoundRobin,
+				RetryRequests:      true,
+				MaxRetryBodyMemory: tc.maxRetryBodyMemory,
+				MaxRetryBodySize:   tc.maxRetryBodySize,
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(payload))
+			tlb.GetRequestHandler()(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+			if tc.expectedStatus == http.StatusOK && rec.Body.String() != payload {
+				t.Fatalf("Expected body %q to be replayed, got %q", payload, rec.Body.String())
+			}
+		})
 	}
 }
 
-func TestWeightedRoundRobinNextServer(t *testing.T) {
+func TestRequestHandlerRetryPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		idempotencyKey string
+		policy         RetryPolicy
+		failingStatus  int
+		expectedStatus int
+	}{
+		{name: "idempotent method", method: http.MethodGet, failingStatus: http.StatusServiceUnavailable, expectedStatus: http.StatusOK},
+		{name: "non idempotent method", method: http.MethodPost, failingStatus: http.StatusServiceUnavailable, expectedStatus: http.StatusServiceUnavailable},
+		{name: "idempotency key", method: http.MethodPost, idempotencyKey: "abc", failingStatus: http.StatusServiceUnavailable, expectedStatus: http.StatusOK},
+		{name: "status not retried", method: http.MethodGet, failingStatus: http.StatusNotImplemented, expectedStatus: http.StatusNotImplemented},
+		{name: "custom status", method: http.MethodGet, policy: RetryPolicy{StatusCodes: []int{http.StatusTooManyRequests}}, failingStatus: http.StatusTooManyRequests, expectedStatus: http.StatusOK},
+		{name: "single attempt", method: http.MethodGet, policy: RetryPolicy{MaxAttempts: 1}, failingStatus: http.StatusServiceUnavailable, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "failing", tc.failingStatus)
+			}))
+			defer failing.Close()
+			healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("healthy"))
+			}))
+			defer healthy.Close()
+
+			failingURL, _ := url.Parse(failing.URL)
+			healthyURL, _ := url.Parse(healthy.URL)
+			tlb := &TinyLoadBalancer{
+				Servers: []*server.Server{
+					server.NewServer(failingURL, 0),
+					server.NewServer(healthyURL, 0),
+				},
+				Strategy:      constants.RoundRobin,
+				RetryRequests: true,
+				RetryPolicy:   tc.policy,
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/", nil)
+			if tc.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tc.idempotencyKey)
+			}
+			tlb.GetRequestHandler()(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequestHandlerPerTryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	slowURL, _ := url.Parse(slow.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
 	tlb := &TinyLoadBalancer{
 		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 5),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 2),
+			server.NewServer(slowURL, 0),
+			server.NewServer(healthyURL, 0),
 		},
-		NextServer: 0,
+		Strategy:      constants.RoundRobin,
+		RetryRequests: true,
+		RetryPolicy:   RetryPolicy{PerTryTimeout: 50 * time.Millisecond},
 	}
+	rec := httptest.NewRecorder()
+	tlb.GetRequestHandler()(rec, httptest.NewRequest(http.MethodGet, "/", nil))
 
-	testCases := []struct {
-		expectedHost   string
-		expectedWeight int
-	}{
-		// cycle one
-		{expectedHost: "localhost:8080", expectedWeight: 4},
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8082", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 3},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8082", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 2},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 0},
-
-		// cycle two
-		{expectedHost: "localhost:8080", expectedWeight: 4},
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8082", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 3},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8082", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 2},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 0},
+	if rec.Body.String() != "healthy" {
+		t.Fatalf("Expected timed out request to be retried, got %d %q", rec.Code, rec.Body.String())
 	}
+}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerWeightedRoundRobin(ip)
-		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+func TestRequestHandlerIgnoresClientCancellation(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+	}))
+	defer slow.Close()
+
+	slowURL, _ := url.Parse(slow.URL)
+	pool := []*server.Server{server.NewServer(slowURL, 0), server.NewServer(slowURL, 0)}
+	for _, s := range pool {
+		s.CircuitBreaker = server.CircuitBreaker{Enabled: true, ConsecutiveFailures: 1}
+	}
+	tlb := &TinyLoadBalancer{
+		Servers:          pool,
+		Strategy:         constants.RoundRobin,
+		OutlierDetection: OutlierDetection{Consecutive5xx: 1, MaxEjectionPercent: 100},
+	}
+	handler := tlb.GetRequestHandler()
+
+	// Clients that give up before the server answers don't count against it
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		cancel()
+	}
+	for _, s := range pool {
+		if s.IsEjected() || s.GetBreakerState() != server.BreakerClosed {
+			t.Fatalf("Expected %s not to be ejected or broken when clients cancel", s.URL)
 		}
-		if server.CurrentWeight != tc.expectedWeight {
-			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.Weight)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(0.2, 1, time.Minute)
+	for i := 0; i < 10; i++ {
+		budget.RecordRequest()
+	}
+
+	// 1 min retry + 20% of 10 requests
+	for i := 0; i < 3; i++ {
+		if !budget.TryRetry() {
+			t.Fatalf("Expected retry %d to be allowed", i)
 		}
 	}
+	if budget.TryRetry() {
+		t.Fatalf("Expected retry budget to be exhausted")
+	}
+
+	stats := budget.Stats()
+	if stats.Requests != 10 || stats.Retries != 3 || stats.Remaining != 0 {
+		t.Fatalf("Unexpected budget stats %+v", stats)
+	}
 }
 
-func TestWeightedRoundRobinNextServerOneUnhealthyServer(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 5),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
-			{
-				URL:           &url.URL{Host: "localhost:8082"},
-				Healthy:       false,
-				Weight:        2,
-				CurrentWeight: 2,
-			},
-		},
-		NextServer: 0,
+func TestRetryBudgetWindowExpires(t *testing.T) {
+	budget := NewRetryBudget(0, 1, 50*time.Millisecond)
+	if !budget.TryRetry() {
+		t.Fatalf("Expected retry to be allowed")
+	}
+	if budget.TryRetry() {
+		t.Fatalf("Expected retry budget to be exhausted")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !budget.TryRetry() {
+		t.Fatalf("Expected retry to be allowed once the window has passed")
 	}
+}
 
+func TestGetBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 50 * time.Millisecond
 	testCases := []struct {
-		expectedHost   string
-		expectedWeight int
+		retry      int
+		maxBackoff time.Duration
 	}{
-		// cycle one
-		{expectedHost: "localhost:8080", expectedWeight: 4},
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8080", expectedWeight: 3},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 2},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 0},
-
-		// cycle two
-		{expectedHost: "localhost:8080", expectedWeight: 4},
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8080", expectedWeight: 3},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 2},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
-		{expectedHost: "localhost:8080", expectedWeight: 1},
-		{expectedHost: "localhost:8080", expectedWeight: 0},
+		{retry: 1, maxBackoff: 10 * time.Millisecond},
+		{retry: 2, maxBackoff: 20 * time.Millisecond},
+		{retry: 3, maxBackoff: 40 * time.Millisecond},
+		{retry: 4, maxBackoff: 50 * time.Millisecond},
+		{retry: 40, maxBackoff: 50 * time.Millisecond},
 	}
 
 	for i, tc := range testCases {
-		server, err := tlb.getNextServerWeightedRoundRobin(ip)
-		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
-		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
-		}
-		if server.CurrentWeight != tc.expectedWeight {
-			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.Weight)
+		for j := 0; j < 100; j++ {
+			backoff := getBackoff(tc.retry, base, max)
+			if backoff < 0 || backoff > tc.maxBackoff {
+				t.Fatalf("Test case %d: Expected backoff between 0 and %s, got %s", i, tc.maxBackoff, backoff)
+			}
 		}
 	}
 }
 
-func TestWeightedRoundRobinNextServerFirstUnhealthyServer(t *testing.T) {
+func TestRequestHandlerRetryBudgetExhausted(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failing", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	failingURL, _ := url.Parse(failing.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
 	tlb := &TinyLoadBalancer{
 		Servers: []*server.Server{
-			{
-				URL:           &url.URL{Host: "localhost:8080"},
-				Healthy:       false,
-				Weight:        5,
-				CurrentWeight: 5,
-			},
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 2),
+			server.NewServer(failingURL, 0),
+			server.NewServer(healthyURL, 0),
 		},
-		NextServer: 0,
+		Strategy:      constants.RoundRobin,
+		RetryRequests: true,
+		RetryPolicy:   RetryPolicy{BudgetRatio: 0.01, BudgetMinRetries: 1},
 	}
+	handler := tlb.GetRequestHandler()
 
-	testCases := []struct {
-		expectedHost   string
-		expectedWeight int
-	}{
-		// cycle one
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8082", expectedWeight: 1},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8082", expectedWeight: 0},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected first request to be retried, got %d", rec.Code)
+	}
 
-		// cycle two
-		{expectedHost: "localhost:8081", expectedWeight: 2},
-		{expectedHost: "localhost:8082", expectedWeight: 1},
-		{expectedHost: "localhost:8081", expectedWeight: 1},
-		{expectedHost: "localhost:8082", expectedWeight: 0},
-		{expectedHost: "localhost:8081", expectedWeight: 0},
+	tlb.Servers[0].Healthy = true
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected failed response once the budget is exhausted, got %d", rec.Code)
+	}
+	if tlb.Stats().RetryBudget.Remaining != 0 {
+		t.Fatalf("Expected no retries to be left, got %+v", tlb.Stats().RetryBudget)
 	}
+}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerWeightedRoundRobin(ip)
-		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
-		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+func TestRequestHandlerStickySession(t *testing.T) {
+	pool := []*server.Server{}
+	for _, name := range []string{"first", "second"} {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "backend", Value: name})
+			w.Write([]byte(name))
+		}))
+		defer backend.Close()
+		backendURL, _ := url.Parse(backend.URL)
+		pool = append(pool, server.NewServer(backendURL, 0))
+	}
+
+	tlb := &TinyLoadBalancer{
+		Servers:  pool,
+		Strategy: constants.RoundRobin,
+		StrategyOptions: strategy.Options{
+			StickySession: &strategy.StickySessionOptions{CookieName: "session", Secret: []byte("secret")},
+		},
+	}
+	handler := tlb.GetRequestHandler()
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("Expected sticky and backend cookies, got %v", cookies)
+	}
+	var sticky *http.Cookie
+	for _, c := range cookies {
+		if c.Name == "session" {
+			sticky = c
 		}
-		if server.CurrentWeight != tc.expectedWeight {
-			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.Weight)
+	}
+	if sticky == nil {
+		t.Fatalf("Expected sticky session cookie, got %v", cookies)
+	}
+
+	expectedBody := rec.Body.String()
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(sticky)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Body.String() != expectedBody {
+			t.Fatalf("Test case %d: Expected sticky response %s, got %s", i, expectedBody, rec.Body.String())
 		}
 	}
 }
 
-func TestIpHashingNextServer(t *testing.T) {
+func TestRequestHandlerStickySessionEarlyHints(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Write([]byte("OK"))
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
 	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+		Servers:  []*server.Server{server.NewServer(backendURL, 0)},
+		Strategy: constants.RoundRobin,
+		StrategyOptions: strategy.Options{
+			StickySession: &strategy.StickySessionOptions{CookieName: "session", Secret: []byte("secret")},
 		},
-		NextServer: 0,
 	}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
 
-	testCases := []struct {
-		ip           string
-		expectedHost string
-	}{
-		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
-		{ip: "127.0.0.2", expectedHost: "localhost:8080"},
-		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
-		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
-		{ip: "127.0.0.2", expectedHost: "localhost:8080"},
-		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+	var hints textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			hints = header
+			return nil
+		},
 	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, lb.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error making request: %s", err.Error())
+	}
+	res.Body.Close()
+	if hints.Get("Link") == "" || hints.Get("Set-Cookie") != "" {
+		t.Fatalf("Expected the early hints without the sticky cookie, got %v", hints)
+	}
+	if len(res.Cookies()) != 1 || res.Cookies()[0].Name != "session" {
+		t.Fatalf("Expected the sticky cookie on the final response, got %v", res.Cookies())
+	}
+}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerIPHashing(tc.ip)
+func TestGetServerSlowStart(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 1),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 1),
+	}
+	pool[0].HealthySince = time.Now().Add(-time.Hour)
+	pool[1].SlowStart = server.SlowStart{Duration: time.Hour, MinWeightPercent: 10}
+	pool[0].SlowStart = pool[1].SlowStart
+	tlb := &TinyLoadBalancer{}
+	s, _ := strategy.New(constants.RoundRobin, strategy.Options{})
 
+	// Round robin would give each server half of the requests, the new server only gets about 10%
+	counts := map[*server.Server]int{}
+	for i := 0; i < 10000; i++ {
+		server, err := tlb.getServer(httptest.NewRequest(http.MethodGet, "/", nil), s, pool)
 		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+			t.Fatalf("Error getting server: %s", err)
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		counts[server]++
+	}
+	if counts[pool[1]] < 500 || counts[pool[1]] > 1500 {
+		t.Fatalf("Expected about 1000 requests on the server in slow start, got %d", counts[pool[1]])
+	}
+
+	// Without a warm server to take over, slow start is skipped
+	pool[0].Healthy = false
+	server, err := tlb.getServer(httptest.NewRequest(http.MethodGet, "/", nil), s, pool)
+	if err != nil || server != pool[1] {
+		t.Fatalf("Expected the server in slow start to get the request, got %v", err)
+	}
+}
+
+func TestGetServerSlowStartKeepsStickySessions(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 1),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 1),
+	}
+	pool[1].SlowStart = server.SlowStart{Duration: time.Hour, MinWeightPercent: 10}
+	pool[0].HealthySince = time.Now().Add(-time.Hour)
+	tlb := &TinyLoadBalancer{}
+	s, _ := strategy.New(constants.RoundRobin, strategy.Options{
+		StickySession: &strategy.StickySessionOptions{CookieName: "session", Secret: []byte("secret")},
+	})
+
+	// Find the cookie of the server in slow start
+	var cookie *http.Cookie
+	for cookie == nil {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		server, _ := tlb.getServer(req, s, pool)
+		if server == pool[1] {
+			s.(strategy.ResponseHeaderHook).OnResponseHeader(req, server, rec.Header())
+			cookie = rec.Result().Cookies()[0]
+		}
+	}
+
+	// Clients pinned to the ramping server stay on it
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		if server, _ := tlb.getServer(req, s, pool); server != pool[1] {
+			t.Fatalf("Test case %d: Expected the pinned client to stay on the server in slow start", i)
 		}
 	}
 }
 
-func TestIpHashingNextServerUnhealthyServer(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
-		},
-		NextServer: 0,
+func newTestPool(n int) []*server.Server {
+	pool := make([]*server.Server, n)
+	for i := range pool {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 8081+i))
+		pool[i] = server.NewServer(u, 1)
 	}
-	tlb.Servers[0].Healthy = false
 
-	testCases := []struct {
-		ip           string
-		expectedHost string
-	}{
-		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
-		{ip: "127.0.0.2", expectedHost: "localhost:8081"}, // 8080 is unhealthy, so it goes to next healthy server
-		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
-		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
-		{ip: "127.0.0.2", expectedHost: "localhost:8081"}, // 8080 is unhealthy, so it goes to next healthy server
-		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+	return pool
+}
+
+func TestOutlierDetectionConsecutiveErrors(t *testing.T) {
+	pool := newTestPool(3)
+	now := time.Now()
+	bus := events.NewBus()
+	ejections, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+	d := newOutlierDetector(OutlierDetection{Consecutive5xx: 3, ConsecutiveGatewayErrors: 2, MaxEjectionPercent: 100}, bus)
+	d.now = func() time.Time { return now }
+
+	// A success resets the count
+	d.Record(pool, pool[0], http.StatusInternalServerError)
+	d.Record(pool, pool[0], http.StatusInternalServerError)
+	d.Record(pool, pool[0], http.StatusOK)
+	d.Record(pool, pool[0], http.StatusInternalServerError)
+	if isEjected(pool[0], now) {
+		t.Fatalf("Expected server not to be ejected after a success")
+	}
+	d.Record(pool, pool[0], http.StatusInternalServerError)
+	d.Record(pool, pool[0], http.StatusInternalServerError)
+	if !isEjected(pool[0], now) {
+		t.Fatalf("Expected server to be ejected after 3 consecutive 5xx")
 	}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerIPHashing(tc.ip)
+	d.Record(pool, pool[1], http.StatusBadGateway)
+	d.Record(pool, pool[1], http.StatusGatewayTimeout)
+	if !isEjected(pool[1], now) {
+		t.Fatalf("Expected server to be ejected after 2 consecutive gateway errors")
+	}
 
-		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+	for _, s := range pool[:2] {
+		e := <-ejections
+		if e.Type != events.ServerEjected || e.Server != s.URL.String() {
+			t.Fatalf("Expected ejection event for %s, got %+v", s.URL, e)
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+	}
+}
+
+func TestOutlierDetectionEjectionTimeGrows(t *testing.T) {
+	pool := newTestPool(2)
+	now := time.Now()
+	d := newOutlierDetector(OutlierDetection{
+		Consecutive5xx:     1,
+		BaseEjectionTime:   10 * time.Second,
+		MaxEjectionTime:    25 * time.Second,
+		MaxEjectionPercent: 100,
+		Interval:           time.Hour,
+	}, nil)
+	d.now = func() time.Time { return now }
+
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second} {
+		d.Record(pool, pool[0], http.StatusInternalServerError)
+		if ejectedFor := pool[0].EjectedUntil.Sub(now); ejectedFor != expected {
+			t.Fatalf("Expected server to be ejected for %s, got %s", expected, ejectedFor)
 		}
+		now = pool[0].EjectedUntil
 	}
 }
 
-func TestLeastConnections(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
-		},
-		NextServer: 0,
+func TestOutlierDetectionMaxEjectionPercent(t *testing.T) {
+	pool := newTestPool(3)
+	now := time.Now()
+	d := newOutlierDetector(OutlierDetection{Consecutive5xx: 1, MaxEjectionPercent: 50}, nil)
+	d.now = func() time.Time { return now }
+
+	for _, s := range pool {
+		d.Record(pool, s, http.StatusInternalServerError)
 	}
-	tlb.Servers[0].ActiveConnections = 2
-	tlb.Servers[1].ActiveConnections = 5
-	tlb.Servers[2].ActiveConnections = 0
 
-	testCases := []struct {
-		expectedHost string
-	}{
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8080"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8080"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8080"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8080"},
-		{expectedHost: "localhost:8081"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8080"},
+	ejected := 0
+	for _, s := range pool {
+		if isEjected(s, now) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Fatalf("Expected 2 ejected servers, got %d", ejected)
 	}
+}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerLeastConnections("")
+func TestOutlierDetectionKeepsLastAvailableServer(t *testing.T) {
+	pool := newTestPool(3)
+	pool[0].SetHealthy(false)
+	pool[1].Disabled = true
+	now := time.Now()
+	bus := events.NewBus()
+	received, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+	d := newOutlierDetector(OutlierDetection{Consecutive5xx: 1, MaxEjectionPercent: 100}, bus)
+	d.now = func() time.Time { return now }
 
-		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+	d.Record(pool, pool[2], http.StatusInternalServerError)
+	if isEjected(pool[2], now) {
+		t.Fatalf("Expected the last available server not to be ejected")
+	}
+	if e := <-received; e.Type != events.EjectionSkipped || e.Server != pool[2].URL.String() {
+		t.Fatalf("Expected an ejection skipped event, got %+v", e)
+	}
+}
+
+func TestOutlierDetectionSuccessRate(t *testing.T) {
+	pool := newTestPool(5)
+	now := time.Now()
+	d := newOutlierDetector(OutlierDetection{
+		Interval:                 time.Second,
+		SuccessRateRequestVolume: 10,
+		SuccessRateMinimumHosts:  5,
+		MaxEjectionPercent:       100,
+	}, nil)
+	d.now = func() time.Time { return now }
+
+	for i, s := range pool {
+		for j := 0; j < 10; j++ {
+			status := http.StatusOK
+			// The last server fails every other request, never enough in a row to be ejected
+			if i == len(pool)-1 && j%2 == 0 {
+				status = http.StatusInternalServerError
+			}
+			d.Record(pool, s, status)
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+	}
+	if isEjected(pool[len(pool)-1], now) {
+		t.Fatalf("Expected server not to be ejected before the interval ends")
+	}
+
+	now = now.Add(time.Second)
+	d.Record(pool, pool[0], http.StatusOK)
+	for i, s := range pool {
+		if expected := i == len(pool)-1; isEjected(s, now) != expected {
+			t.Fatalf("Expected server %d ejected to be %t", i, expected)
 		}
-		server.ActiveConnections++
 	}
 }
-func TestLeastConnectionsUnhealthyServer(t *testing.T) {
+
+func TestRequestHandlerRetriesOnUntriedServer(t *testing.T) {
+	requests := make([]int, 3)
+	pool := make([]*server.Server, len(requests))
+	for i := range pool {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests[i]++
+			http.Error(w, "failing", http.StatusServiceUnavailable)
+		}))
+		defer backend.Close()
+		backendURL, _ := url.Parse(backend.URL)
+		pool[i] = server.NewServer(backendURL, 0)
+	}
+
+	// Random could pick a failed server again, unless tried servers are skipped
 	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
-		},
-		NextServer: 0,
+		Servers:       pool,
+		Strategy:      constants.Random,
+		RetryRequests: true,
 	}
-	tlb.Servers[0].ActiveConnections = 2
-	tlb.Servers[1].ActiveConnections = 5
-	tlb.Servers[2].ActiveConnections = 0
-	tlb.Servers[0].Healthy = false
+	rec := httptest.NewRecorder()
+	tlb.GetRequestHandler()(rec, httptest.NewRequest(http.MethodGet, "/", nil))
 
-	testCases := []struct {
-		expectedHost string
-	}{
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8082"},
-		{expectedHost: "localhost:8081"},
-		{expectedHost: "localhost:8082"},
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", rec.Code)
+	}
+	for i, count := range requests {
+		if count != 1 {
+			t.Fatalf("Expected server %d to get 1 request, got %d", i, count)
+		}
 	}
+}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerLeastConnections("")
+func TestGetServerSkipsOpenCircuitBreaker(t *testing.T) {
+	pool := newTestPool(2)
+	for _, s := range pool {
+		s.CircuitBreaker = server.CircuitBreaker{Enabled: true, ConsecutiveFailures: 1}
+	}
+	pool[0].RecordResult(false)
 
+	tlb := &TinyLoadBalancer{}
+	rr, _ := strategy.New(constants.RoundRobin, strategy.Options{})
+	for i := 0; i < 4; i++ {
+		s, err := tlb.getServer(httptest.NewRequest(http.MethodGet, "/", nil), rr, pool)
 		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+			t.Fatalf("Error getting server: %s", err.Error())
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		if s != pool[1] {
+			t.Fatalf("Expected server with an open circuit breaker to be skipped")
 		}
-		server.ActiveConnections++
+	}
+
+	pool[1].RecordResult(false)
+	if _, err := tlb.getServer(httptest.NewRequest(http.MethodGet, "/", nil), rr, pool); err == nil {
+		t.Fatalf("Expected no server when every circuit breaker is open")
 	}
 }
 
-func TestGetNextServerLeastResponseTimeNoHealthyServers(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
-		},
-		NextServer: 0,
+func TestReload(t *testing.T) {
+	pool := newTestPool(3)
+	tlb := &TinyLoadBalancer{Servers: pool, Strategy: constants.WeightedRoundRobin}
+	tlb.GetRequestHandler()
+	pool[0].Mut.Lock()
+	pool[0].ActiveConnections, pool[0].RequestsCount = 2, 10
+	pool[0].Mut.Unlock()
+	currentStrategy := tlb.strategy
+
+	// Keep the first server with a new weight, drop the second and third, add a new one
+	addedURL, _ := url.Parse("http://localhost:9090")
+	added := server.NewServer(addedURL, 1)
+	next := []*server.Server{server.NewServer(pool[0].URL, 5), added}
+
+	reloaded, result, err := tlb.Reload(&TinyLoadBalancer{Servers: next, Strategy: constants.WeightedRoundRobin}, false)
+	if err != nil {
+		t.Fatalf("Error reloading: %s", err.Error())
+	}
+	if result != (ReloadResult{Added: 1, Removed: 2, Updated: 1}) {
+		t.Fatalf("Unexpected reload result %+v", result)
+	}
+	if len(reloaded) != 2 || reloaded[0] != pool[0] || reloaded[1] != added {
+		t.Fatalf("Expected the existing server to be kept and the new one added")
+	}
+	if pool[0].Weight != 5 || pool[0].ActiveConnections != 2 || pool[0].RequestsCount != 10 {
+		t.Fatalf("Expected the kept server to get the new weight and keep its stats")
+	}
+	if tlb.strategy != currentStrategy {
+		t.Fatalf("Expected the strategy to keep its state")
 	}
-	tlb.Servers[0].Healthy = false
-	tlb.Servers[1].Healthy = false
-	tlb.Servers[2].Healthy = false
 
-	_, err := tlb.getNextServerLeastResponseTime("")
-	if err == nil {
-		t.Fatalf("Expected error when no healthy servers are available")
+	_, _, err = tlb.Reload(&TinyLoadBalancer{Servers: next, Strategy: constants.LeastConnections}, true)
+	if err != nil {
+		t.Fatalf("Error reloading: %s", err.Error())
+	}
+	if _, ok := tlb.strategy.(*strategy.LeastConnections); !ok || tlb.Strategy != constants.LeastConnections {
+		t.Fatalf("Expected the strategy to be replaced")
+	}
+
+	// An unknown strategy leaves everything as it was
+	_, _, err = tlb.Reload(&TinyLoadBalancer{Strategy: "unknown"}, true)
+	if err == nil || len(tlb.Servers) != 2 {
+		t.Fatalf("Expected the reload to fail and keep the pool")
 	}
 }
 
-func TestGetNextServerLeastResponseTime(t *testing.T) {
-	tlb := &TinyLoadBalancer{
-		Servers: []*server.Server{
-			server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
-			server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
-		},
-		NextServer: 0,
+func TestDrain(t *testing.T) {
+	release := make(chan struct{})
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+			}
+			w.Write([]byte(name))
+		}))
 	}
+	first, second := newBackend("first"), newBackend("second")
+	defer first.Close()
+	defer second.Close()
+	firstURL, _ := url.Parse(first.URL)
+	secondURL, _ := url.Parse(second.URL)
+	pool := []*server.Server{server.NewServer(firstURL, 0), server.NewServer(secondURL, 0)}
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+	tlb := &TinyLoadBalancer{Servers: pool, Strategy: constants.RoundRobin, Events: bus}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
 
-	testCases := []struct {
-		expectedHost string
-		serverIdx    int
-		duration     time.Duration
-	}{
-		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond},
-		{expectedHost: "localhost:8081", serverIdx: 1, duration: 50 * time.Millisecond},
-		{expectedHost: "localhost:8082", serverIdx: 2, duration: 200 * time.Millisecond},
-		{expectedHost: "localhost:8081", serverIdx: 1, duration: 100 * time.Millisecond},
-		{expectedHost: "localhost:8081", serverIdx: 1, duration: 600 * time.Millisecond},
-		{expectedHost: "localhost:8080", serverIdx: 0, duration: 700 * time.Millisecond},
-		{expectedHost: "localhost:8082", serverIdx: 2, duration: 100 * time.Millisecond},
-		{expectedHost: "localhost:8082", serverIdx: 2, duration: 1500 * time.Millisecond},
-		{expectedHost: "localhost:8081", serverIdx: 1, duration: 1500 * time.Millisecond},
-		{expectedHost: "localhost:8080", serverIdx: 0, duration: 900 * time.Millisecond},
+	// A slow request is still running on the server when it starts draining
+	done := make(chan string)
+	go func() {
+		res, err := http.Get(lb.URL + "/slow")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		done <- string(body)
+	}()
+	busy := -1
+	for busy == -1 {
+		for i, s := range pool {
+			s.Mut.Lock()
+			if s.ActiveConnections == 1 {
+				busy = i
+			}
+			s.Mut.Unlock()
+		}
+		time.Sleep(time.Millisecond)
+	}
+	names := []string{"first", "second"}
+	next := []*server.Server{server.NewServer(firstURL, 0), server.NewServer(secondURL, 0)}
+	next[busy].SetDraining(true)
+	if _, _, err := tlb.Reload(&TinyLoadBalancer{Servers: next, Strategy: constants.RoundRobin}, false); err != nil {
+		t.Fatalf("Error reloading: %s", err.Error())
 	}
 
-	for i, tc := range testCases {
-		server, err := tlb.getNextServerLeastResponseTime("")
+	for i := 0; i < 4; i++ {
+		res, err := http.Get(lb.URL)
 		if err != nil {
-			t.Fatalf("Error getting next server: %s", err.Error())
+			t.Fatalf("Error making request: %s", err.Error())
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != names[1-busy] {
+			t.Fatalf("Test case %d: Expected draining server to get no new requests, got %s", i, body)
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("Expected no event while a request is running, got %+v", e)
+	default:
+	}
+
+	close(release)
+	if body := <-done; body != names[busy] {
+		t.Fatalf("Expected the running request to finish on the draining server, got %s", body)
+	}
+	select {
+	case e := <-ch:
+		if e.Type != events.ServerDrained || e.Server != pool[busy].URL.String() || e.Reason != "no active connections" {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a drained event")
+	}
+	if !pool[busy].IsDrained() {
+		t.Fatalf("Expected the server to be drained")
+	}
+}
+
+func TestDrainAbortedResponse(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+		panic(http.ErrAbortHandler)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	pool := []*server.Server{server.NewServer(backendURL, 0)}
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+	tlb := &TinyLoadBalancer{Servers: pool, Strategy: constants.RoundRobin, Events: bus, DrainTimeout: time.Minute}
+	lb := httptest.NewServer(tlb.GetRequestHandler())
+	defer lb.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if res, err := http.Get(lb.URL); err == nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
 		}
-		if server.URL.Host != tc.expectedHost {
-			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+	}()
+	for {
+		pool[0].Mut.Lock()
+		activeConnections := pool[0].ActiveConnections
+		pool[0].Mut.Unlock()
+		if activeConnections == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	next := []*server.Server{server.NewServer(backendURL, 0)}
+	next[0].SetDraining(true)
+	if _, _, err := tlb.Reload(&TinyLoadBalancer{Servers: next, Strategy: constants.RoundRobin}, false); err != nil {
+		t.Fatalf("Error reloading: %s", err.Error())
+	}
+
+	// The response of the last request breaks off, which ends the drain as well
+	close(release)
+	<-done
+	select {
+	case e := <-ch:
+		if e.Type != events.ServerDrained || e.Reason != "no active connections" {
+			t.Fatalf("Unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a drained event before the drain timeout")
+	}
+	if stats := tlb.Stats(); !stats.Servers[0].Drained {
+		t.Fatalf("Expected the stats to show the server as drained, got %+v", stats.Servers[0])
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	pool := newTestPool(2)
+	pool[0].ActiveConnections = 1
+	bus := events.NewBus()
+	ch, unsubscribe := bus.Channel(10)
+	defer unsubscribe()
+	tlb := &TinyLoadBalancer{Servers: pool, Strategy: constants.RoundRobin, Events: bus}
+	tlb.GetRequestHandler()
+
+	next := []*server.Server{server.NewServer(pool[0].URL, 1), server.NewServer(pool[1].URL, 1)}
+	next[0].SetDraining(true)
+	_, _, err := tlb.Reload(&TinyLoadBalancer{Servers: next, Strategy: constants.RoundRobin, DrainTimeout: 20 * time.Millisecond}, false)
+	if err != nil {
+		t.Fatalf("Error reloading: %s", err.Error())
+	}
+
+	select {
+	case e := <-ch:
+		if e.Type != events.ServerDrained || e.Reason != "drain timeout expired with 1 active connections" {
+			t.Fatalf("Unexpected event %+v", e)
 		}
-		tlb.updateServerStats(tlb.Servers[tc.serverIdx], tc.duration)
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a drained event when the timeout expires")
 	}
 }
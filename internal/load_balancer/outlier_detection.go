@@ -0,0 +1,287 @@
+package loadbalancer
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+// OutlierDetection ejects servers based on the responses of live traffic, like Envoy's outlier detection.
+// A server is ejected after too many failed responses in a row, or when its success rate falls
+// too far below the rest of the pool. Zero values fall back to the defaults from the constants package.
+type OutlierDetection struct {
+	Consecutive5xx           int
+	ConsecutiveGatewayErrors int
+	Interval                 time.Duration
+	BaseEjectionTime         time.Duration
+	MaxEjectionTime          time.Duration
+	MaxEjectionPercent       int
+	SuccessRateMinimumHosts  int
+	SuccessRateRequestVolume int
+	SuccessRateStdevFactor   float64
+}
+
+type outlierDetector struct {
+	Mut       sync.Mutex
+	config    OutlierDetection
+	events    *events.Bus
+	servers   map[*server.Server]*outlierStats
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// outlierStats are the responses of a server since the last interval
+type outlierStats struct {
+	consecutive5xx           int
+	consecutiveGatewayErrors int
+	requests                 int
+	successes                int
+}
+
+func newOutlierDetector(config OutlierDetection, bus *events.Bus) *outlierDetector {
+	return &outlierDetector{
+		config:  config,
+		events:  bus,
+		servers: map[*server.Server]*outlierStats{},
+	}
+}
+
+// Record counts the response of a server and ejects it once it qualifies as an outlier.
+// Transport errors are recorded with the 502 or 504 status code written for them.
+func (d *outlierDetector) Record(pool []*server.Server, s *server.Server, statusCode int) {
+	d.Mut.Lock()
+	defer d.Mut.Unlock()
+
+	now := d.getNow()
+	if d.lastSweep.IsZero() {
+		d.lastSweep = now
+	}
+
+	stats := d.getStats(s)
+	stats.requests++
+	switch {
+	case isGatewayError(statusCode):
+		stats.consecutive5xx++
+		stats.consecutiveGatewayErrors++
+	case statusCode >= http.StatusInternalServerError:
+		stats.consecutive5xx++
+		stats.consecutiveGatewayErrors = 0
+	default:
+		stats.successes++
+		stats.consecutive5xx = 0
+		stats.consecutiveGatewayErrors = 0
+	}
+
+	if stats.consecutive5xx >= d.getConsecutive5xx() {
+		d.eject(pool, s, now, "consecutive 5xx")
+	} else if stats.consecutiveGatewayErrors >= d.getConsecutiveGatewayErrors() {
+		d.eject(pool, s, now, "consecutive gateway errors")
+	}
+
+	if now.Sub(d.lastSweep) >= d.getInterval() {
+		d.sweep(pool, now)
+	}
+}
+
+// sweep ejects the servers with a success rate too far below the pool mean,
+// then starts a new interval
+func (d *outlierDetector) sweep(pool []*server.Server, now time.Time) {
+	rates := map[*server.Server]float64{}
+	mean := 0.0
+	for _, s := range pool {
+		stats := d.getStats(s)
+		if stats.requests >= d.getSuccessRateRequestVolume() && !isEjected(s, now) {
+			rates[s] = float64(stats.successes) / float64(stats.requests)
+			mean += rates[s]
+		}
+	}
+
+	if len(rates) >= d.getSuccessRateMinimumHosts() {
+		mean /= float64(len(rates))
+		variance := 0.0
+		for _, rate := range rates {
+			variance += (rate - mean) * (rate - mean)
+		}
+		stdev := math.Sqrt(variance / float64(len(rates)))
+		threshold := mean - d.getSuccessRateStdevFactor()*stdev
+		for _, s := range pool {
+			if rate, ok := rates[s]; ok && rate < threshold {
+				d.eject(pool, s, now, "success rate")
+			}
+		}
+	}
+
+	for _, s := range pool {
+		stats := d.getStats(s)
+		stats.requests, stats.successes = 0, 0
+
+		// Servers that stayed in the pool for a whole interval are forgiven one ejection
+		s.Mut.Lock()
+		if s.Ejections > 0 && !now.Before(s.EjectedUntil) {
+			s.Ejections--
+		}
+		s.Mut.Unlock()
+	}
+	d.lastSweep = now
+}
+
+func (d *outlierDetector) eject(pool []*server.Server, s *server.Server, now time.Time, reason string) {
+	if isEjected(s, now) {
+		return
+	}
+
+	// Keep enough of the pool, ejecting every server would turn a partial outage into a full one.
+	// Servers that are down, disabled or draining are gone already, so they count as ejected.
+	unavailable := 0
+	for _, p := range pool {
+		if !p.IsAvailableAt(now) {
+			unavailable++
+		}
+	}
+	if unavailable+1 >= len(pool) && s.IsAvailableAt(now) {
+		d.keepServer(s, reason, "it is the last available server")
+		return
+	}
+	if unavailable*100 >= d.getMaxEjectionPercent()*len(pool) {
+		d.keepServer(s, reason, "too many servers are unavailable")
+		return
+	}
+
+	s.Mut.Lock()
+	s.Ejections++
+	ejectionTime := time.Duration(s.Ejections) * d.getBaseEjectionTime()
+	ejectionTime = min(ejectionTime, max(d.getMaxEjectionTime(), d.getBaseEjectionTime()))
+	s.EjectedUntil = now.Add(ejectionTime)
+	s.Mut.Unlock()
+
+	stats := d.getStats(s)
+	stats.consecutive5xx, stats.consecutiveGatewayErrors = 0, 0
+	slog.Default().Warn("Ejecting server", slog.Attr{
+		Key:   "Server",
+		Value: slog.StringValue(s.URL.String()),
+	}, slog.Attr{
+		Key:   "reason",
+		Value: slog.StringValue(reason),
+	}, slog.Attr{
+		Key:   "duration",
+		Value: slog.DurationValue(ejectionTime),
+	})
+	d.events.Emit(events.Event{
+		Type:   events.ServerEjected,
+		Server: s.URL.String(),
+		Reason: reason,
+	})
+}
+
+// keepServer leaves an outlier in the pool, its failures count again from zero
+// so the warning isn't repeated for every failed request
+func (d *outlierDetector) keepServer(s *server.Server, reason string, why string) {
+	stats := d.getStats(s)
+	stats.consecutive5xx, stats.consecutiveGatewayErrors = 0, 0
+	slog.Default().Warn("Not ejecting server, "+why, slog.Attr{
+		Key:   "Server",
+		Value: slog.StringValue(s.URL.String()),
+	}, slog.Attr{
+		Key:   "reason",
+		Value: slog.StringValue(reason),
+	})
+	d.events.Emit(events.Event{
+		Type:   events.EjectionSkipped,
+		Server: s.URL.String(),
+		Reason: reason + ", " + why,
+	})
+}
+
+// keep forgets the servers that are no longer in the pool
+func (d *outlierDetector) keep(pool []*server.Server) {
+	d.Mut.Lock()
+	defer d.Mut.Unlock()
+
+	for s := range d.servers {
+		if !slices.Contains(pool, s) {
+			delete(d.servers, s)
+		}
+	}
+}
+
+func (d *outlierDetector) getStats(s *server.Server) *outlierStats {
+	stats, ok := d.servers[s]
+	if !ok {
+		stats = &outlierStats{}
+		d.servers[s] = stats
+	}
+
+	return stats
+}
+
+func isEjected(s *server.Server, now time.Time) bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return now.Before(s.EjectedUntil)
+}
+
+func isGatewayError(statusCode int) bool {
+	return statusCode == http.StatusBadGateway ||
+		statusCode == http.StatusServiceUnavailable ||
+		statusCode == http.StatusGatewayTimeout
+}
+
+func (d *outlierDetector) getConsecutive5xx() int {
+	return getOrDefault(d.config.Consecutive5xx, constants.DefaultConsecutive5xx)
+}
+
+func (d *outlierDetector) getConsecutiveGatewayErrors() int {
+	return getOrDefault(d.config.ConsecutiveGatewayErrors, constants.DefaultConsecutiveGatewayErrors)
+}
+
+func (d *outlierDetector) getInterval() time.Duration {
+	return getOrDefault(d.config.Interval, constants.DefaultOutlierDetectionInterval)
+}
+
+func (d *outlierDetector) getBaseEjectionTime() time.Duration {
+	return getOrDefault(d.config.BaseEjectionTime, constants.DefaultBaseEjectionTime)
+}
+
+func (d *outlierDetector) getMaxEjectionTime() time.Duration {
+	return getOrDefault(d.config.MaxEjectionTime, constants.DefaultMaxEjectionTime)
+}
+
+func (d *outlierDetector) getMaxEjectionPercent() int {
+	return getOrDefault(d.config.MaxEjectionPercent, constants.DefaultMaxEjectionPercent)
+}
+
+func (d *outlierDetector) getSuccessRateMinimumHosts() int {
+	return getOrDefault(d.config.SuccessRateMinimumHosts, constants.DefaultSuccessRateMinimumHosts)
+}
+
+func (d *outlierDetector) getSuccessRateRequestVolume() int {
+	return getOrDefault(d.config.SuccessRateRequestVolume, constants.DefaultSuccessRateRequestVolume)
+}
+
+func (d *outlierDetector) getSuccessRateStdevFactor() float64 {
+	return getOrDefault(d.config.SuccessRateStdevFactor, constants.DefaultSuccessRateStdevFactor)
+}
+
+func (d *outlierDetector) getNow() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+
+	return time.Now()
+}
+
+func getOrDefault[T int | float64 | time.Duration](value T, defaultValue T) T {
+	if value > 0 {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,113 @@
+package loadbalancer
+
+import (
+	"log/slog"
+	"reflect"
+
+	"github.com/tiny-loadbalancer/internal/server"
+	"github.com/tiny-loadbalancer/internal/strategy"
+)
+
+// ReloadResult counts how the pool changed in a reload
+type ReloadResult struct {
+	Added   int
+	Removed int
+	Updated int
+}
+
+// Reload switches the load balancer to the servers and settings of next in one step, requests
+// that are already running finish with the old pool. Servers are matched by URL, the ones that
+// stay keep their stats, connection counts, health and circuit breaker, only their weight, slow
+// start, circuit breaker settings and whether they are disabled or draining are taken from next. The strategy keeps its state unless
+// resetStrategy is set, which is needed when the strategy or its options changed.
+// It returns the new pool, which has the order of next.Servers.
+func (tlb *TinyLoadBalancer) Reload(next *TinyLoadBalancer, resetStrategy bool) ([]*server.Server, ReloadResult, error) {
+	var nextStrategy strategy.Strategy
+	if resetStrategy {
+		var err error
+		nextStrategy, err = strategy.New(next.Strategy, next.StrategyOptions)
+		if err != nil {
+			return nil, ReloadResult{}, err
+		}
+	}
+
+	tlb.Mut.Lock()
+	defer tlb.Mut.Unlock()
+
+	existing := make(map[string]*server.Server, len(tlb.Servers))
+	for _, s := range tlb.Servers {
+		existing[s.URL.String()] = s
+	}
+
+	result := ReloadResult{}
+	pool := make([]*server.Server, 0, len(next.Servers))
+	var draining []*server.Server
+	for _, s := range next.Servers {
+		current, ok := existing[s.URL.String()]
+		if !ok {
+			pool = append(pool, s)
+			result.Added++
+			if s.IsDraining() {
+				draining = append(draining, s)
+			}
+			continue
+		}
+		delete(existing, s.URL.String())
+
+		current.Mut.Lock()
+		if current.Weight != s.Weight || current.SlowStart != s.SlowStart || current.CircuitBreaker != s.CircuitBreaker ||
+			current.Disabled != s.Disabled || current.Draining != s.Draining {
+			result.Updated++
+		}
+		current.Weight = s.Weight
+		current.SlowStart = s.SlowStart
+		current.CircuitBreaker = s.CircuitBreaker
+		current.Disabled = s.Disabled
+		current.Mut.Unlock()
+		if current.SetDraining(s.IsDraining()) {
+			draining = append(draining, current)
+		}
+		pool = append(pool, current)
+	}
+	result.Removed = len(existing)
+
+	tlb.Servers = pool
+	tlb.RetryRequests = next.RetryRequests
+	tlb.MaxRetryBodyMemory = next.MaxRetryBodyMemory
+	tlb.MaxRetryBodySize = next.MaxRetryBodySize
+	tlb.DrainTimeout = next.DrainTimeout
+	if !reflect.DeepEqual(tlb.RetryPolicy, next.RetryPolicy) {
+		tlb.RetryPolicy = next.RetryPolicy
+		tlb.retryBudget = nil
+	}
+	if tlb.OutlierDetection != next.OutlierDetection {
+		tlb.OutlierDetection = next.OutlierDetection
+		tlb.outlierDetector = nil
+	} else if tlb.outlierDetector != nil {
+		tlb.outlierDetector.keep(pool)
+	}
+	if nextStrategy != nil {
+		tlb.Strategy = next.Strategy
+		tlb.StrategyOptions = next.StrategyOptions
+		tlb.strategy = nextStrategy
+	}
+	for _, s := range draining {
+		tlb.watchDrain(s)
+	}
+
+	slog.Default().Info("Reloaded servers", slog.Attr{
+		Key:   "added",
+		Value: slog.IntValue(result.Added),
+	}, slog.Attr{
+		Key:   "removed",
+		Value: slog.IntValue(result.Removed),
+	}, slog.Attr{
+		Key:   "updated",
+		Value: slog.IntValue(result.Updated),
+	}, slog.Attr{
+		Key:   "strategy",
+		Value: slog.StringValue(string(tlb.Strategy)),
+	})
+
+	return pool, result, nil
+}
@@ -0,0 +1,114 @@
+package loadbalancer
+
+import (
+	"net/http"
+)
+
+// streamingResponseWriter sits between the reverse proxy and the client connection.
+// Headers are held back until the backend sends its status line, after which the
+// response is committed and everything is streamed straight to the client.
+// When shouldRetry accepts the status code, the response is not committed at all,
+// so the request can be sent to another server.
+type streamingResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	code        int
+	shouldRetry func(code int) bool
+	// onHeader adds the headers of the load balancer to the final response, informational
+	// responses go out without them
+	onHeader  func(header http.Header)
+	onCommit  func()
+	committed bool
+	discarded bool
+}
+
+func newStreamingResponseWriter(w http.ResponseWriter, shouldRetry func(code int) bool) *streamingResponseWriter {
+	return &streamingResponseWriter{
+		w:           w,
+		header:      make(http.Header),
+		shouldRetry: shouldRetry,
+	}
+}
+
+// Header returns the held back headers until the response is committed. After that it is the
+// header of the client response, so trailers the proxy sets after the body reach the client.
+func (rw *streamingResponseWriter) Header() http.Header {
+	if rw.committed {
+		return rw.w.Header()
+	}
+
+	return rw.header
+}
+
+func (rw *streamingResponseWriter) WriteHeader(code int) {
+	if rw.committed || rw.discarded {
+		return
+	}
+
+	// Informational responses (e.g. 103 Early Hints) are passed through as they arrive
+	if code >= http.StatusContinue && code < http.StatusOK {
+		rw.copyHeaders()
+		rw.w.WriteHeader(code)
+		clear(rw.w.Header())
+		return
+	}
+
+	rw.code = code
+	if rw.shouldRetry != nil && rw.shouldRetry(code) {
+		rw.discarded = true
+		return
+	}
+
+	if rw.onHeader != nil {
+		rw.onHeader(rw.header)
+	}
+	rw.copyHeaders()
+	rw.w.WriteHeader(code)
+	rw.committed = true
+	if rw.onCommit != nil {
+		rw.onCommit()
+	}
+	rw.Flush()
+}
+
+func (rw *streamingResponseWriter) Write(b []byte) (int, error) {
+	if !rw.committed && !rw.discarded {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.discarded {
+		return len(b), nil
+	}
+
+	return rw.w.Write(b)
+}
+
+func (rw *streamingResponseWriter) Flush() {
+	if !rw.committed {
+		return
+	}
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying connection
+func (rw *streamingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// StatusCode returns the status the backend answered with, or 0 if it never answered
+func (rw *streamingResponseWriter) StatusCode() int {
+	return rw.code
+}
+
+// Discarded reports whether the response was dropped, so the request can be retried
+func (rw *streamingResponseWriter) Discarded() bool {
+	return rw.discarded
+}
+
+func (rw *streamingResponseWriter) copyHeaders() {
+	dst := rw.w.Header()
+	for k, v := range rw.header {
+		dst[k] = v
+	}
+}
@@ -0,0 +1,123 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// RetryPolicy decides which failed requests are sent to another server.
+// Zero values fall back to the defaults from the constants package.
+type RetryPolicy struct {
+	Methods             []string
+	StatusCodes         []int
+	Errors              []constants.RetryError
+	MaxAttempts         int
+	PerTryTimeout       time.Duration
+	BudgetRatio         float64
+	BudgetMinRetries    int
+	BudgetWindow        time.Duration
+	BackoffBaseInterval time.Duration
+	BackoffMaxInterval  time.Duration
+}
+
+// AllowsRequest reports whether the request is safe to replay.
+// Requests carrying an Idempotency-Key header are retryable regardless of their method.
+func (p RetryPolicy) AllowsRequest(r *http.Request) bool {
+	if r.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+
+	methods := p.Methods
+	if len(methods) == 0 {
+		methods = constants.DefaultRetryMethods
+	}
+
+	return slices.Contains(methods, r.Method)
+}
+
+// AllowsResponse reports whether the status code or transport error of an attempt qualifies for a retry
+func (p RetryPolicy) AllowsResponse(statusCode int, err error) bool {
+	if err != nil {
+		retryErrors := p.Errors
+		if len(retryErrors) == 0 {
+			retryErrors = constants.RetryErrors
+		}
+		kind, ok := getRetryError(err)
+
+		return ok && slices.Contains(retryErrors, kind)
+	}
+
+	statusCodes := p.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = constants.DefaultRetryStatusCodes
+	}
+
+	return slices.Contains(statusCodes, statusCode)
+}
+
+// GetMaxAttempts returns the total number of attempts, including the first one
+func (p RetryPolicy) GetMaxAttempts(serversCount int) int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+
+	return serversCount
+}
+
+// NewRetryBudget creates the retry budget described by the policy
+func (p RetryPolicy) NewRetryBudget() *RetryBudget {
+	ratio := p.BudgetRatio
+	if ratio <= 0 {
+		ratio = constants.DefaultRetryBudgetRatio
+	}
+	minRetries := p.BudgetMinRetries
+	if minRetries <= 0 {
+		minRetries = constants.DefaultRetryBudgetMinRetries
+	}
+	window := p.BudgetWindow
+	if window <= 0 {
+		window = constants.DefaultRetryBudgetWindow
+	}
+
+	return NewRetryBudget(ratio, minRetries, window)
+}
+
+// GetBackoff returns how long to wait before the given retry
+func (p RetryPolicy) GetBackoff(retry int) time.Duration {
+	base := p.BackoffBaseInterval
+	if base <= 0 {
+		base = constants.DefaultRetryBackoffBaseInterval
+	}
+	max := p.BackoffMaxInterval
+	if max <= 0 {
+		max = 10 * base
+	}
+
+	return getBackoff(retry, base, max)
+}
+
+func getRetryError(err error) (constants.RetryError, bool) {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return constants.RetryOnTimeout, true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return constants.RetryOnConnectFailure, true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return constants.RetryOnReset, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,127 @@
+package loadbalancer
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const retryBudgetBuckets = 10
+
+type retryBudgetBucket struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// RetryBudget limits retries to a ratio of the requests seen over a sliding window,
+// so a partial outage can't multiply the load on the remaining servers.
+// MinRetries are always allowed, so low traffic services can still retry.
+type RetryBudget struct {
+	Mut        sync.Mutex
+	Ratio      float64
+	MinRetries int
+	Window     time.Duration
+	buckets    [retryBudgetBuckets]retryBudgetBucket
+}
+
+type RetryBudgetStats struct {
+	Ratio      float64 `json:"ratio"`
+	MinRetries int     `json:"minRetries"`
+	Window     string  `json:"window"`
+	Requests   int     `json:"requests"`
+	Retries    int     `json:"retries"`
+	Remaining  int     `json:"remaining"`
+}
+
+func NewRetryBudget(ratio float64, minRetries int, window time.Duration) *RetryBudget {
+	return &RetryBudget{
+		Ratio:      ratio,
+		MinRetries: minRetries,
+		Window:     window,
+	}
+}
+
+// RecordRequest counts an incoming request towards the budget
+func (b *RetryBudget) RecordRequest() {
+	b.Mut.Lock()
+	defer b.Mut.Unlock()
+
+	b.getBucket(time.Now()).requests++
+}
+
+// TryRetry withdraws a retry from the budget, if there is one left
+func (b *RetryBudget) TryRetry() bool {
+	b.Mut.Lock()
+	defer b.Mut.Unlock()
+
+	now := time.Now()
+	if b.remaining(now) <= 0 {
+		return false
+	}
+	b.getBucket(now).retries++
+
+	return true
+}
+
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.Mut.Lock()
+	defer b.Mut.Unlock()
+
+	now := time.Now()
+	requests, retries := b.count(now)
+
+	return RetryBudgetStats{
+		Ratio:      b.Ratio,
+		MinRetries: b.MinRetries,
+		Window:     b.Window.String(),
+		Requests:   requests,
+		Retries:    retries,
+		Remaining:  b.remaining(now),
+	}
+}
+
+func (b *RetryBudget) remaining(now time.Time) int {
+	requests, retries := b.count(now)
+	allowed := b.MinRetries + int(math.Floor(b.Ratio*float64(requests)))
+
+	return allowed - retries
+}
+
+func (b *RetryBudget) count(now time.Time) (int, int) {
+	requests, retries := 0, 0
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.Window {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	return requests, retries
+}
+
+func (b *RetryBudget) getBucket(now time.Time) *retryBudgetBucket {
+	bucketSize := b.Window / retryBudgetBuckets
+	start := now.Truncate(bucketSize)
+	bucket := &b.buckets[(start.UnixNano()/int64(bucketSize))%retryBudgetBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = retryBudgetBucket{start: start}
+	}
+
+	return bucket
+}
+
+// getBackoff returns the delay before the given retry, growing exponentially
+// from base up to max, with full jitter so retries from many clients spread out
+func getBackoff(retry int, base time.Duration, max time.Duration) time.Duration {
+	backoff := max
+	if retry < 32 && base<<(retry-1) < max {
+		backoff = base << (retry - 1)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
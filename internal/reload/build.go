@@ -0,0 +1,389 @@
+package reload
+
+import (
+	"cmp"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/health"
+	lb "github.com/tiny-loadbalancer/internal/load_balancer"
+	"github.com/tiny-loadbalancer/internal/server"
+	"github.com/tiny-loadbalancer/internal/strategy"
+)
+
+// NewLoadBalancer builds the load balancer of the config, its servers start out healthy
+func NewLoadBalancer(c *config.Config) (*lb.TinyLoadBalancer, error) {
+	retryPolicy, err := getRetryPolicy(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	outlierDetection, err := getOutlierDetection(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outlier detection: %w", err)
+	}
+	strategyOptions, err := getStrategyOptions(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid strategy options: %w", err)
+	}
+	servers, err := getServers(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid servers: %w", err)
+	}
+	var drainTimeout time.Duration
+	if err := config.ParseDurations(config.Duration(c.DrainTimeout, &drainTimeout)); err != nil {
+		return nil, fmt.Errorf("invalid drain timeout: %w", err)
+	}
+
+	return &lb.TinyLoadBalancer{
+		Port:               c.Port,
+		Servers:            servers,
+		Strategy:           c.Strategy,
+		StrategyOptions:    strategyOptions,
+		RetryRequests:      c.RetryRequests,
+		MaxRetryBodyMemory: c.MaxRetryBodyMemory,
+		MaxRetryBodySize:   c.MaxRetryBodySize,
+		RetryPolicy:        retryPolicy,
+		OutlierDetection:   outlierDetection,
+		DrainTimeout:       drainTimeout,
+	}, nil
+}
+
+// NewChecker builds the health checks of the servers, they belong to the servers of the config in order
+func NewChecker(c *config.Config, servers []*server.Server, logger *slog.Logger, bus *events.Bus) (*health.Checker, error) {
+	healthCheckInterval, err := time.ParseDuration(c.HealthCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := getHealthCheckTargets(c, servers, healthCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &health.Checker{
+		Targets: targets,
+		Client: &http.Client{
+			// Redirects are judged by their own status code
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Logger: logger,
+		Events: bus,
+	}, nil
+}
+
+func getServers(config *config.Config) ([]*server.Server, error) {
+	var servers []*server.Server
+	for _, s := range config.Servers {
+		parsedUrl, err := url.Parse(s.Url)
+		if err != nil {
+			return nil, err
+		}
+		server := server.NewServer(parsedUrl, s.Weight)
+		server.Disabled = s.Disabled
+		server.SetDraining(s.Draining)
+
+		// Servers can override the slow start of the pool
+		slowStart := config.SlowStart
+		if s.SlowStart != nil {
+			slowStart = *s.SlowStart
+		}
+		server.SlowStart, err = getSlowStart(slowStart)
+		if err != nil {
+			return nil, err
+		}
+
+		circuitBreaker := config.CircuitBreaker
+		if s.CircuitBreaker != nil {
+			circuitBreaker = *s.CircuitBreaker
+		}
+		server.CircuitBreaker, err = getCircuitBreaker(circuitBreaker)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+
+	return servers, nil
+}
+
+func getHealthCheckTargets(config *config.Config, servers []*server.Server, interval time.Duration) ([]health.Target, error) {
+	targets := make([]health.Target, 0, len(servers))
+	for i, s := range config.Servers {
+		target, err := getHealthCheckTarget(config.HealthCheck, s.HealthCheck)
+		if err != nil {
+			return nil, err
+		}
+		target.Server = servers[i]
+		target.Interval = interval
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// getHealthCheckTarget builds the health check of a server, the fields it sets override the ones of the pool
+func getHealthCheckTarget(c config.HealthCheck, override *config.HealthCheck) (health.Target, error) {
+	if override != nil {
+		c.Type = cmp.Or(override.Type, c.Type)
+		c.Path = cmp.Or(override.Path, c.Path)
+		c.Method = cmp.Or(override.Method, c.Method)
+		c.Host = cmp.Or(override.Host, c.Host)
+		c.Body = cmp.Or(override.Body, c.Body)
+		c.BodyRegex = cmp.Or(override.BodyRegex, c.BodyRegex)
+		c.Send = cmp.Or(override.Send, c.Send)
+		c.Expect = cmp.Or(override.Expect, c.Expect)
+		c.Service = cmp.Or(override.Service, c.Service)
+		c.MinCertValidity = cmp.Or(override.MinCertValidity, c.MinCertValidity)
+		c.InsecureSkipVerify = override.InsecureSkipVerify || c.InsecureSkipVerify
+		c.Timeout = cmp.Or(override.Timeout, c.Timeout)
+		c.UnhealthyInterval = cmp.Or(override.UnhealthyInterval, c.UnhealthyInterval)
+		c.Jitter = cmp.Or(override.Jitter, c.Jitter)
+		c.HealthyThreshold = cmp.Or(override.HealthyThreshold, c.HealthyThreshold)
+		c.UnhealthyThreshold = cmp.Or(override.UnhealthyThreshold, c.UnhealthyThreshold)
+		if len(override.ExpectedStatuses) > 0 {
+			c.ExpectedStatuses = override.ExpectedStatuses
+		}
+		if len(override.Headers) > 0 {
+			c.Headers = override.Headers
+		}
+		if len(override.Command) > 0 {
+			c.Command = override.Command
+		}
+	}
+
+	target := health.Target{
+		HealthyThreshold:   c.HealthyThreshold,
+		UnhealthyThreshold: c.UnhealthyThreshold,
+	}
+	check := health.Check{
+		Type:               constants.HealthCheckType(c.Type),
+		Path:               c.Path,
+		Method:             c.Method,
+		Host:               c.Host,
+		Headers:            http.Header{},
+		Body:               c.Body,
+		Send:               c.Send,
+		Expect:             c.Expect,
+		Service:            c.Service,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		Command:            c.Command,
+	}
+	if check.Type == constants.HealthCheckScript && len(check.Command) == 0 {
+		return target, fmt.Errorf("script health checks need a command")
+	}
+	for name, value := range c.Headers {
+		check.Headers.Set(name, value)
+	}
+	for _, s := range c.ExpectedStatuses {
+		statusRange, err := health.ParseStatusRange(s)
+		if err != nil {
+			return target, err
+		}
+		check.ExpectedStatuses = append(check.ExpectedStatuses, statusRange)
+	}
+	if c.BodyRegex != "" {
+		bodyRegex, err := regexp.Compile(c.BodyRegex)
+		if err != nil {
+			return target, err
+		}
+		check.BodyRegex = bodyRegex
+	}
+
+	if err := config.ParseDurations(
+		config.Duration(c.Timeout, &check.Timeout),
+		config.Duration(c.MinCertValidity, &check.MinCertValidity),
+		config.Duration(c.UnhealthyInterval, &target.UnhealthyInterval),
+		config.Duration(c.Jitter, &target.Jitter),
+	); err != nil {
+		return target, err
+	}
+	target.Check = check
+
+	return target, nil
+}
+
+// NewWebhooks builds the webhook subscribers of the config
+func NewWebhooks(conf *config.Config) ([]*events.Webhook, error) {
+	webhooks := make([]*events.Webhook, 0, len(conf.Webhooks))
+	for _, c := range conf.Webhooks {
+		webhook := &events.Webhook{
+			URL:     c.Url,
+			Headers: http.Header{},
+		}
+		for name, value := range c.Headers {
+			webhook.Headers.Set(name, value)
+		}
+		for _, t := range c.Events {
+			webhook.Types = append(webhook.Types, events.Type(t))
+		}
+		if err := config.ParseDurations(config.Duration(c.Timeout, &webhook.Timeout)); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func getSlowStart(c config.SlowStart) (server.SlowStart, error) {
+	slowStart := server.SlowStart{
+		Curve:            c.Curve,
+		MinWeightPercent: c.MinWeightPercent,
+	}
+	if err := config.ParseDurations(config.Duration(c.Duration, &slowStart.Duration)); err != nil {
+		return slowStart, err
+	}
+
+	return slowStart, nil
+}
+
+func getCircuitBreaker(c config.CircuitBreaker) (server.CircuitBreaker, error) {
+	circuitBreaker := server.CircuitBreaker{
+		Enabled:             c.Enabled,
+		ConsecutiveFailures: c.ConsecutiveFailures,
+		ErrorRatePercent:    c.ErrorRatePercent,
+		MinRequests:         c.MinRequests,
+		HalfOpenRequests:    c.HalfOpenRequests,
+	}
+
+	if err := config.ParseDurations(
+		config.Duration(c.Window, &circuitBreaker.Window),
+		config.Duration(c.Cooldown, &circuitBreaker.Cooldown),
+	); err != nil {
+		return circuitBreaker, err
+	}
+
+	return circuitBreaker, nil
+}
+
+func getRetryPolicy(conf *config.Config) (lb.RetryPolicy, error) {
+	policy := lb.RetryPolicy{
+		Methods:          conf.RetryPolicy.Methods,
+		StatusCodes:      conf.RetryPolicy.StatusCodes,
+		Errors:           conf.RetryPolicy.Errors,
+		MaxAttempts:      conf.RetryPolicy.MaxAttempts,
+		BudgetRatio:      conf.RetryPolicy.Budget.Ratio,
+		BudgetMinRetries: conf.RetryPolicy.Budget.MinRetries,
+	}
+
+	if err := config.ParseDurations(
+		config.Duration(conf.RetryPolicy.PerTryTimeout, &policy.PerTryTimeout),
+		config.Duration(conf.RetryPolicy.Budget.Window, &policy.BudgetWindow),
+		config.Duration(conf.RetryPolicy.Backoff.BaseInterval, &policy.BackoffBaseInterval),
+		config.Duration(conf.RetryPolicy.Backoff.MaxInterval, &policy.BackoffMaxInterval),
+	); err != nil {
+		return policy, err
+	}
+
+	return policy, nil
+}
+
+func getOutlierDetection(conf *config.Config) (lb.OutlierDetection, error) {
+	c := conf.OutlierDetection
+	outlierDetection := lb.OutlierDetection{
+		Consecutive5xx:           c.Consecutive5xx,
+		ConsecutiveGatewayErrors: c.ConsecutiveGatewayErrors,
+		MaxEjectionPercent:       c.MaxEjectionPercent,
+		SuccessRateMinimumHosts:  c.SuccessRateMinimumHosts,
+		SuccessRateRequestVolume: c.SuccessRateRequestVolume,
+		SuccessRateStdevFactor:   c.SuccessRateStdevFactor,
+	}
+
+	if err := config.ParseDurations(
+		config.Duration(c.Interval, &outlierDetection.Interval),
+		config.Duration(c.BaseEjectionTime, &outlierDetection.BaseEjectionTime),
+		config.Duration(c.MaxEjectionTime, &outlierDetection.MaxEjectionTime),
+	); err != nil {
+		return outlierDetection, err
+	}
+
+	return outlierDetection, nil
+}
+
+func getStrategyOptions(conf *config.Config) (strategy.Options, error) {
+	hashKey, err := strategy.NewKeyFunc(
+		conf.HashKey.Source,
+		conf.HashKey.Fallback,
+		conf.HashKey.TrustForwardedFor,
+	)
+	if err != nil {
+		return strategy.Options{}, err
+	}
+
+	options := strategy.Options{
+		VirtualNodes: conf.VirtualNodes,
+		HashKey:      hashKey,
+	}
+	if err := config.ParseDurations(
+		config.Duration(conf.LeastResponseTime.HalfLife, &options.LatencyHalfLife),
+		config.Duration(conf.LeastResponseTime.Probation, &options.LatencyProbation),
+	); err != nil {
+		return options, err
+	}
+	if conf.StickySession.Enabled {
+		options.StickySession, err = getStickySessionOptions(conf)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	return options, nil
+}
+
+func getStickySessionOptions(conf *config.Config) (*strategy.StickySessionOptions, error) {
+	c := conf.StickySession
+	options := &strategy.StickySessionOptions{
+		CookieName:   c.CookieName,
+		Secure:       c.Secure,
+		HTTPOnly:     c.HTTPOnly,
+		Secret:       []byte(c.Secret),
+		KeepDraining: c.KeepDraining,
+	}
+	if options.CookieName == "" {
+		options.CookieName = constants.DefaultStickySessionCookieName
+	}
+	if err := config.ParseDurations(config.Duration(c.TTL, &options.TTL)); err != nil {
+		return nil, err
+	}
+
+	switch c.SameSite {
+	case "lax":
+		options.SameSite = http.SameSiteLaxMode
+	case "strict":
+		options.SameSite = http.SameSiteStrictMode
+	case "none":
+		options.SameSite = http.SameSiteNoneMode
+	}
+
+	if len(options.Secret) == 0 {
+		secret, err := getFallbackSecret()
+		if err != nil {
+			return nil, err
+		}
+		options.Secret = secret
+	}
+
+	return options, nil
+}
+
+// getFallbackSecret signs the sticky session cookies when no secret is configured. It is made once
+// per process, so sessions survive reloads and admin API changes, but not a restart.
+var getFallbackSecret = sync.OnceValues(func() ([]byte, error) {
+	slog.Default().Warn("No sticky session secret configured, sessions won't survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+})
@@ -0,0 +1,33 @@
+package reload
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/config"
+)
+
+func TestGetStickySessionOptionsKeepsFallbackSecret(t *testing.T) {
+	c := &config.Config{StickySession: config.StickySession{Enabled: true}}
+	first, err := getStickySessionOptions(c)
+	if err != nil {
+		t.Fatalf("Error getting sticky session options: %s", err.Error())
+	}
+	c.VirtualNodes = 200
+	second, err := getStickySessionOptions(c)
+	if err != nil {
+		t.Fatalf("Error getting sticky session options: %s", err.Error())
+	}
+	if len(first.Secret) != 32 || !bytes.Equal(first.Secret, second.Secret) {
+		t.Fatalf("Expected one fallback secret for every reload")
+	}
+
+	c.StickySession.Secret = "configured"
+	configured, err := getStickySessionOptions(c)
+	if err != nil {
+		t.Fatalf("Error getting sticky session options: %s", err.Error())
+	}
+	if string(configured.Secret) != "configured" {
+		t.Fatalf("Expected the configured secret, got %q", configured.Secret)
+	}
+}
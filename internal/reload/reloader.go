@@ -0,0 +1,259 @@
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/admin"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/health"
+	lb "github.com/tiny-loadbalancer/internal/load_balancer"
+)
+
+// ReadConfig reads and validates the config file
+func ReadConfig(path string) (*config.Config, error) {
+	config := &config.Config{}
+	c, err := config.ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	err = config.ValidateConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reloader applies changes of the config file and of the admin API to the running load balancer,
+// an invalid config is logged and the running one is kept. It also runs the health checks,
+// which are replaced on every change.
+type Reloader struct {
+	mut     sync.Mutex
+	path    string
+	config  *config.Config
+	tlb     *lb.TinyLoadBalancer
+	checker *health.Checker
+	logger  *slog.Logger
+	events  *events.Bus
+	// modTime and size of the config file when it was last read or written
+	modTime time.Time
+	size    int64
+	stopped bool
+}
+
+// New returns a reloader for the load balancer built from c, which was read from the file at path
+func New(path string, c *config.Config, tlb *lb.TinyLoadBalancer, logger *slog.Logger, bus *events.Bus) *Reloader {
+	return &Reloader{
+		path:   path,
+		config: c,
+		tlb:    tlb,
+		logger: logger,
+		events: bus,
+	}
+}
+
+// Start starts the health checks of the running servers
+func (r *Reloader) Start() error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	checker, err := NewChecker(r.config, r.tlb.GetServers(), r.logger, r.events)
+	if err != nil {
+		return err
+	}
+	checker.Start(context.Background())
+	r.checker = checker
+
+	return nil
+}
+
+// WatchSignals reloads the config on every SIGHUP
+func (r *Reloader) WatchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		r.logger.Info("Received SIGHUP, reloading config")
+		r.Reload()
+	}
+}
+
+// WatchFile reloads the config when the modification time or size of the file changes
+func (r *Reloader) WatchFile(interval time.Duration) {
+	r.mut.Lock()
+	r.updateFileInfo()
+	r.mut.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !r.hasFileChanged() {
+			continue
+		}
+		r.logger.Info("Config file changed, reloading config")
+		r.Reload()
+	}
+}
+
+func (r *Reloader) Reload() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.updateFileInfo()
+	c, err := ReadConfig(r.path)
+	if err != nil {
+		r.logger.Error("Invalid config, keeping the running one", "error", err)
+		return
+	}
+	if err := r.apply(c); err != nil {
+		r.logger.Error("Error reloading config, keeping the running one", "error", err)
+	}
+}
+
+// Config returns a copy of the running config
+func (r *Reloader) Config() *config.Config {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	c := *r.config
+	c.Servers = slices.Clone(r.config.Servers)
+
+	return &c
+}
+
+// Apply switches to a config changed through the admin API, it is written back to the config file when
+// admin.persist is set
+func (r *Reloader) Apply(c *config.Config) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if err := c.ValidateConfig(c); err != nil {
+		return fmt.Errorf("%w: %w", admin.ErrInvalidConfig, err)
+	}
+	if err := r.apply(c); err != nil {
+		return err
+	}
+	if !c.Admin.Persist {
+		return nil
+	}
+
+	return r.persist()
+}
+
+// apply must be called with r.mut held
+func (r *Reloader) apply(c *config.Config) error {
+	if r.stopped {
+		return errors.New("the load balancer is shutting down")
+	}
+	next, err := NewLoadBalancer(c)
+	if err != nil {
+		return fmt.Errorf("%w: %w", admin.ErrInvalidConfig, err)
+	}
+	checker, err := NewChecker(c, next.Servers, r.logger, r.events)
+	if err != nil {
+		return fmt.Errorf("%w: invalid health check: %w", admin.ErrInvalidConfig, err)
+	}
+
+	pool, result, err := r.tlb.Reload(next, hasStrategyChanged(r.config, c))
+	if err != nil {
+		return err
+	}
+
+	// Servers that stayed in the pool are probed with their existing state
+	for i := range checker.Targets {
+		checker.Targets[i].Server = pool[i]
+	}
+	if r.checker != nil {
+		r.checker.Stop()
+	}
+	checker.Start(context.Background())
+	r.checker = checker
+
+	if c.Port != r.config.Port {
+		r.logger.Warn("Port changes need a restart", "port", r.config.Port)
+	}
+	if !reflect.DeepEqual(c.Webhooks, r.config.Webhooks) {
+		r.logger.Warn("Webhook changes need a restart")
+	}
+	if c.Admin != r.config.Admin {
+		r.logger.Warn("Admin API changes need a restart")
+	}
+	if c.Shutdown.ReadinessPath != r.config.Shutdown.ReadinessPath || c.PidFile != r.config.PidFile {
+		r.logger.Warn("Readiness path and pid file changes need a restart")
+	}
+	r.config = c
+	r.events.Emit(events.Event{
+		Type:   events.ConfigReloaded,
+		Reason: fmt.Sprintf("%d servers added, %d removed, %d updated", result.Added, result.Removed, result.Updated),
+	})
+
+	return nil
+}
+
+// persist writes the running config to the config file, it must be called with r.mut held
+func (r *Reloader) persist() error {
+	content, err := json.MarshalIndent(r.config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The file is replaced in one step, so the file watcher never reads half of it
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.updateFileInfo()
+
+	return nil
+}
+
+// updateFileInfo remembers the state of the config file, so the file watcher skips changes
+// it has seen already. It must be called with r.mut held.
+func (r *Reloader) updateFileInfo() {
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime, r.size = info.ModTime(), info.Size()
+	}
+}
+
+func (r *Reloader) hasFileChanged() bool {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	info, err := os.Stat(r.path)
+
+	return err == nil && (!info.ModTime().Equal(r.modTime) || info.Size() != r.size)
+}
+
+// Stop stops the health checks, later reloads are refused
+func (r *Reloader) Stop() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.stopped = true
+	if r.checker != nil {
+		r.checker.Stop()
+	}
+}
+
+// hasStrategyChanged reports whether the strategy or its options changed, so it has to start over
+func hasStrategyChanged(current *config.Config, next *config.Config) bool {
+	return current.Strategy != next.Strategy ||
+		current.VirtualNodes != next.VirtualNodes ||
+		current.HashKey != next.HashKey ||
+		current.StickySession != next.StickySession ||
+		current.LeastResponseTime != next.LeastResponseTime
+}
@@ -0,0 +1,168 @@
+package reload
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/admin"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/events"
+)
+
+func newTestBackend(t *testing.T) string {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	t.Cleanup(backend.Close)
+
+	return backend.URL
+}
+
+func newTestConfig(urls ...string) *config.Config {
+	c := &config.Config{
+		Port:                8080,
+		Strategy:            constants.RoundRobin,
+		HealthCheckInterval: "1h",
+	}
+	for _, u := range urls {
+		c.Servers = append(c.Servers, config.Server{Url: u, Weight: 1})
+	}
+
+	return c
+}
+
+// newTestReloader starts a reloader for c, which is written to a config file first
+func newTestReloader(t *testing.T, c *config.Config) (*Reloader, *events.Bus) {
+	path := writeTestConfig(t, filepath.Join(t.TempDir(), "config.json"), c)
+	tlb, err := NewLoadBalancer(c)
+	if err != nil {
+		t.Fatalf("Error building load balancer: %s", err.Error())
+	}
+	bus := events.NewBus()
+	tlb.Events = bus
+	r := New(path, c, tlb, slog.New(slog.NewTextHandler(io.Discard, nil)), bus)
+	if err := r.Start(); err != nil {
+		t.Fatalf("Error starting reloader: %s", err.Error())
+	}
+	t.Cleanup(r.Stop)
+
+	return r, bus
+}
+
+func writeTestConfig(t *testing.T, path string, c *config.Config) string {
+	r := &Reloader{path: path, config: c}
+	if err := r.persist(); err != nil {
+		t.Fatalf("Error writing config file: %s", err.Error())
+	}
+
+	return path
+}
+
+func TestReloaderApply(t *testing.T) {
+	first, second, third := newTestBackend(t), newTestBackend(t), newTestBackend(t)
+	r, bus := newTestReloader(t, newTestConfig(first, second))
+	kept := r.tlb.GetServers()[1]
+	reloaded, stop := bus.Channel(1)
+	defer stop()
+
+	r.mut.Lock()
+	err := r.apply(newTestConfig(second, third))
+	r.mut.Unlock()
+	if err != nil {
+		t.Fatalf("Error applying config: %s", err.Error())
+	}
+
+	pool := r.tlb.GetServers()
+	if len(pool) != 2 || pool[0] != kept || pool[1].URL.String() != third {
+		t.Fatalf("Expected the kept server to stay and the new one to be added, got %v", pool)
+	}
+	if len(r.checker.Targets) != 2 || r.checker.Targets[0].Server != kept {
+		t.Fatalf("Expected the health checks to probe the kept server")
+	}
+	if got := r.Config().Servers; len(got) != 2 || got[1].Url != third {
+		t.Fatalf("Expected the running config to be replaced, got %v", got)
+	}
+	e := <-reloaded
+	if e.Type != events.ConfigReloaded || e.Reason != "1 servers added, 1 removed, 0 updated" {
+		t.Fatalf("Expected a config reloaded event, got %+v", e)
+	}
+}
+
+func TestReloaderApplyInvalidConfig(t *testing.T) {
+	url := newTestBackend(t)
+	r, _ := newTestReloader(t, newTestConfig(url))
+	pool := r.tlb.GetServers()
+
+	invalid := newTestConfig(url)
+	invalid.HealthCheck.Timeout = "soon"
+	err := r.Apply(invalid)
+	if !errors.Is(err, admin.ErrInvalidConfig) {
+		t.Fatalf("Expected an invalid config error, got %v", err)
+	}
+	if !reflect.DeepEqual(r.Config(), newTestConfig(url)) || r.tlb.GetServers()[0] != pool[0] {
+		t.Fatalf("Expected the running config to be kept")
+	}
+}
+
+func TestReloaderApplyAfterStop(t *testing.T) {
+	url := newTestBackend(t)
+	r, _ := newTestReloader(t, newTestConfig(url))
+	r.Stop()
+
+	r.mut.Lock()
+	err := r.apply(newTestConfig(url, newTestBackend(t)))
+	r.mut.Unlock()
+	if err == nil {
+		t.Fatalf("Expected the reload to be refused after stopping")
+	}
+	if len(r.tlb.GetServers()) != 1 {
+		t.Fatalf("Expected the pool to be kept")
+	}
+}
+
+func TestReloaderPersist(t *testing.T) {
+	url := newTestBackend(t)
+	r, _ := newTestReloader(t, newTestConfig(url))
+
+	c := newTestConfig(url, newTestBackend(t))
+	c.Servers[0].Weight = 3
+	c.Admin.Persist = true
+	if err := r.Apply(c); err != nil {
+		t.Fatalf("Error applying config: %s", err.Error())
+	}
+
+	written, err := ReadConfig(r.path)
+	if err != nil {
+		t.Fatalf("Error reading persisted config: %s", err.Error())
+	}
+	if !reflect.DeepEqual(written, c) {
+		t.Fatalf("Expected the persisted config to equal the running one, got %+v", written)
+	}
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		t.Fatalf("Error reading config file: %s", err.Error())
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatalf("Error parsing config file: %s", err.Error())
+	}
+	// Only the settings that are set are written, the rest keep their defaults
+	if len(fields) != 5 || fields["retryPolicy"] != nil || fields["admin"] == nil {
+		t.Fatalf("Expected the unset settings to be left out, got %s", content)
+	}
+	if _, err := os.Stat(r.path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Expected the temporary file to be renamed")
+	}
+	if r.hasFileChanged() {
+		t.Fatalf("Expected the file watcher to skip its own write")
+	}
+}
@@ -0,0 +1,137 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/upgrade"
+)
+
+// How long a shutdown waits for connections that were accepted but haven't sent a request yet
+const newConnTimeout = time.Second
+
+// Readiness answers the readiness endpoint, it fails once the load balancer is shutting down
+type Readiness struct {
+	shuttingDown atomic.Bool
+}
+
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rd.shuttingDown.Load() {
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("OK"))
+}
+
+// Servers runs the HTTP servers on the listening sockets
+type Servers struct {
+	listeners *upgrade.Listeners
+	servers   []*http.Server
+	wg        sync.WaitGroup
+	mut       sync.Mutex
+	// Connections that haven't sent a request yet
+	newConns map[net.Conn]struct{}
+}
+
+// New returns servers that stop accepting connections by closing listeners
+func New(listeners *upgrade.Listeners) *Servers {
+	return &Servers{listeners: listeners, newConns: map[net.Conn]struct{}{}}
+}
+
+// Serve runs srv on listener, the error it stops with is sent to serverErr
+func (s *Servers) Serve(srv *http.Server, listener net.Listener, serverErr chan<- error) {
+	srv.ConnState = s.trackConn
+	s.servers = append(s.servers, srv)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		serverErr <- srv.Serve(listener)
+	}()
+}
+
+func (s *Servers) trackConn(conn net.Conn, state http.ConnState) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if state == http.StateNew {
+		s.newConns[conn] = struct{}{}
+	} else {
+		delete(s.newConns, conn)
+	}
+}
+
+func (s *Servers) hasNewConns() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return len(s.newConns) > 0
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done.
+// A server drops connections whose first request arrives after it started shutting down,
+// so connections accepted right before the listeners closed get a moment to send it.
+func (s *Servers) Shutdown(ctx context.Context) error {
+	s.listeners.Close()
+	s.wg.Wait()
+	newConnCtx, cancel := context.WithTimeout(ctx, newConnTimeout)
+	defer cancel()
+	for s.hasNewConns() && newConnCtx.Err() == nil {
+		select {
+		case <-newConnCtx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	errs := make([]error, len(s.servers))
+	var wg sync.WaitGroup
+	for i, srv := range s.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close()
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// GracefulShutdown fails the readiness endpoint and waits for the readiness delay when failReadiness is set,
+// so upstream load balancers stop sending traffic first. Then the servers stop accepting connections
+// and wait for in-flight requests up to the grace period, connections still open after it are closed.
+func GracefulShutdown(c config.Shutdown, servers *Servers, ready *Readiness, failReadiness bool, logger *slog.Logger) error {
+	var delay time.Duration
+	gracePeriod := constants.DefaultShutdownGracePeriod
+	if err := config.ParseDurations(
+		config.Duration(c.ReadinessDelay, &delay),
+		config.Duration(c.GracePeriod, &gracePeriod),
+	); err != nil {
+		return err
+	}
+
+	if failReadiness {
+		ready.shuttingDown.Store(true)
+	}
+	if failReadiness && c.ReadinessPath != "" && delay > 0 {
+		logger.Info("Failing readiness before shutdown", "delay", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	logger.Info("Waiting for in-flight requests", "gracePeriod", gracePeriod)
+	return servers.Shutdown(ctx)
+}
@@ -0,0 +1,165 @@
+package serve
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/upgrade"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestServers serves handler on a local port and returns its address
+func newTestServers(t *testing.T, handler http.Handler) (*Servers, string) {
+	listeners, err := upgrade.New()
+	if err != nil {
+		t.Fatalf("Error creating listeners: %s", err.Error())
+	}
+	listener, err := listeners.Listen("lb", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	servers := New(listeners)
+	servers.Serve(&http.Server{Handler: handler}, listener, make(chan error, 1))
+
+	return servers, listener.Addr().String()
+}
+
+func TestReadiness(t *testing.T) {
+	ready := &Readiness{}
+	servers, _ := newTestServers(t, http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	ready.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 before shutdown, got %d", rec.Code)
+	}
+
+	c := config.Shutdown{ReadinessPath: "/ready", ReadinessDelay: "50ms"}
+	done := make(chan error, 1)
+	go func() {
+		done <- GracefulShutdown(c, servers, ready, true, testLogger)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	rec = httptest.NewRecorder()
+	ready.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 during the readiness delay, got %d", rec.Code)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Error shutting down: %s", err.Error())
+	}
+}
+
+func TestReadinessKeptAfterUpgrade(t *testing.T) {
+	ready := &Readiness{}
+	servers, _ := newTestServers(t, http.NotFoundHandler())
+	if err := GracefulShutdown(config.Shutdown{ReadinessPath: "/ready"}, servers, ready, false, testLogger); err != nil {
+		t.Fatalf("Error shutting down: %s", err.Error())
+	}
+
+	rec := httptest.NewRecorder()
+	ready.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected readiness not to fail when the new process serves, got %d", rec.Code)
+	}
+}
+
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	servers, address := newTestServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("OK"))
+	}))
+
+	res := make(chan *http.Response, 1)
+	go func() {
+		r, err := http.Get("http://" + address)
+		if err != nil {
+			t.Errorf("Error making request: %s", err.Error())
+		}
+		res <- r
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		done <- GracefulShutdown(config.Shutdown{GracePeriod: "5s"}, servers, &Readiness{}, true, testLogger)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected shutdown to wait for the request, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-res; r == nil || r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the in-flight request to complete")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Error shutting down: %s", err.Error())
+	}
+}
+
+func TestShutdownClosesAfterGracePeriod(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	servers, address := newTestServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go http.Get("http://" + address)
+	<-started
+
+	start := time.Now()
+	err := GracefulShutdown(config.Shutdown{GracePeriod: "50ms"}, servers, &Readiness{}, true, testLogger)
+	if err == nil {
+		t.Fatalf("Expected an error when the grace period expires")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected shutdown to stop after the grace period, took %s", elapsed)
+	}
+}
+
+func TestShutdownServesNewConnections(t *testing.T) {
+	servers, address := newTestServers(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Error connecting: %s", err.Error())
+	}
+	defer conn.Close()
+	for !servers.hasNewConns() {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- GracefulShutdown(config.Shutdown{}, servers, &Readiness{}, true, testLogger)
+	}()
+	// The request arrives after the listener stopped accepting connections
+	time.Sleep(50 * time.Millisecond)
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		t.Fatalf("Error sending request: %s", err.Error())
+	}
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Expected the accepted connection to be served, got %s", err.Error())
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.StatusCode)
+	}
+	res.Body.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Error shutting down: %s", err.Error())
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"cmp"
+	"log/slog"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+const breakerBuckets = 10
+
+// CircuitBreaker stops requests to a server that keeps failing them. It trips on too many
+// failures in a row, or when the error rate over the rolling window is too high. After the
+// cooldown a few trial requests are let through, and the breaker closes once they all succeed.
+// It is independent of Healthy, which belongs to the health checks.
+type CircuitBreaker struct {
+	Enabled             bool
+	ConsecutiveFailures int
+	ErrorRatePercent    float64
+	// MinRequests is how many requests the window needs before the error rate counts
+	MinRequests      int
+	Window           time.Duration
+	Cooldown         time.Duration
+	HalfOpenRequests int
+}
+
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+type breaker struct {
+	state               BreakerState
+	openedAt            time.Time
+	consecutiveFailures int
+	trials              int
+	successes           int
+	buckets             [breakerBuckets]breakerBucket
+}
+
+// AllowRequest reports whether the circuit breaker lets a request through to the server,
+// in half-open state every allowed request takes one of the trial slots
+func (s *Server) AllowRequest() bool {
+	s.Mut.Lock()
+	from := s.updateBreakerState(time.Now())
+	allowed := true
+	switch s.breaker.state {
+	case BreakerOpen:
+		allowed = false
+	case BreakerHalfOpen:
+		allowed = s.breaker.trials < s.getHalfOpenRequests()
+		if allowed {
+			s.breaker.trials++
+		}
+	}
+	to := cmp.Or(s.breaker.state, BreakerClosed)
+	s.Mut.Unlock()
+
+	s.logBreakerState(from, to)
+
+	return allowed
+}
+
+// RecordResult feeds the outcome of a request to the circuit breaker
+func (s *Server) RecordResult(success bool) {
+	s.Mut.Lock()
+	now := time.Now()
+	from := s.updateBreakerState(now)
+	switch s.breaker.state {
+	case BreakerClosed:
+		s.recordClosedResult(success, now)
+	case BreakerHalfOpen:
+		// A single failed trial opens the breaker again, it closes once every trial succeeded
+		if !success {
+			s.openBreaker(now)
+			break
+		}
+		s.breaker.successes++
+		if s.breaker.successes >= s.getHalfOpenRequests() {
+			s.breaker = breaker{state: BreakerClosed}
+		}
+	}
+	to := cmp.Or(s.breaker.state, BreakerClosed)
+	s.Mut.Unlock()
+
+	s.logBreakerState(from, to)
+}
+
+// ReleaseRequest gives back the trial slot of a request that ended without a result,
+// e.g. because the client went away, so a half-open breaker doesn't run out of trials
+func (s *Server) ReleaseRequest() {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	if s.breaker.state == BreakerHalfOpen && s.breaker.trials > s.breaker.successes {
+		s.breaker.trials--
+	}
+}
+
+// GetBreakerState returns the state of the circuit breaker, an open breaker
+// is reported as half-open once its cooldown has passed
+func (s *Server) GetBreakerState() BreakerState {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.getBreakerState(time.Now())
+}
+
+// breakerAvailable reports whether the breaker would let a request through,
+// without taking a trial slot
+func (s *Server) breakerAvailable(now time.Time) bool {
+	switch s.getBreakerState(now) {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		return s.breaker.trials < s.getHalfOpenRequests()
+	}
+
+	return true
+}
+
+func (s *Server) getBreakerState(now time.Time) BreakerState {
+	if !s.CircuitBreaker.Enabled || s.breaker.state == "" {
+		return BreakerClosed
+	}
+	if s.breaker.state == BreakerOpen && now.Sub(s.breaker.openedAt) >= s.getCooldown() {
+		return BreakerHalfOpen
+	}
+
+	return s.breaker.state
+}
+
+// updateBreakerState moves an open breaker to half-open once its cooldown has passed,
+// and returns the state it was in before
+func (s *Server) updateBreakerState(now time.Time) BreakerState {
+	from := cmp.Or(s.breaker.state, BreakerClosed)
+	if !s.CircuitBreaker.Enabled {
+		s.breaker = breaker{state: BreakerClosed}
+		return from
+	}
+	s.breaker.state = s.getBreakerState(now)
+
+	return from
+}
+
+func (s *Server) recordClosedResult(success bool, now time.Time) {
+	bucket := s.getBreakerBucket(now)
+	bucket.requests++
+	if success {
+		s.breaker.consecutiveFailures = 0
+		return
+	}
+	bucket.failures++
+	s.breaker.consecutiveFailures++
+
+	consecutiveFailures := s.CircuitBreaker.ConsecutiveFailures
+	if consecutiveFailures <= 0 {
+		consecutiveFailures = constants.DefaultBreakerConsecutiveFailures
+	}
+	errorRatePercent := s.CircuitBreaker.ErrorRatePercent
+	if errorRatePercent <= 0 {
+		errorRatePercent = constants.DefaultBreakerErrorRatePercent
+	}
+	minRequests := s.CircuitBreaker.MinRequests
+	if minRequests <= 0 {
+		minRequests = constants.DefaultBreakerMinRequests
+	}
+
+	requests, failures := 0, 0
+	for _, b := range s.breaker.buckets {
+		if now.Sub(b.start) < s.getBreakerWindow() {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+	if s.breaker.consecutiveFailures >= consecutiveFailures ||
+		(requests >= minRequests && float64(failures*100) >= errorRatePercent*float64(requests)) {
+		s.openBreaker(now)
+	}
+}
+
+func (s *Server) openBreaker(now time.Time) {
+	s.breaker = breaker{state: BreakerOpen, openedAt: now}
+}
+
+func (s *Server) getBreakerBucket(now time.Time) *breakerBucket {
+	bucketSize := s.getBreakerWindow() / breakerBuckets
+	start := now.Truncate(bucketSize)
+	bucket := &s.breaker.buckets[(start.UnixNano()/int64(bucketSize))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+
+	return bucket
+}
+
+func (s *Server) getBreakerWindow() time.Duration {
+	if s.CircuitBreaker.Window > 0 {
+		return s.CircuitBreaker.Window
+	}
+
+	return constants.DefaultBreakerWindow
+}
+
+func (s *Server) getCooldown() time.Duration {
+	if s.CircuitBreaker.Cooldown > 0 {
+		return s.CircuitBreaker.Cooldown
+	}
+
+	return constants.DefaultBreakerCooldown
+}
+
+func (s *Server) getHalfOpenRequests() int {
+	if s.CircuitBreaker.HalfOpenRequests > 0 {
+		return s.CircuitBreaker.HalfOpenRequests
+	}
+
+	return constants.DefaultBreakerHalfOpenRequests
+}
+
+func (s *Server) logBreakerState(from BreakerState, to BreakerState) {
+	if from == to {
+		return
+	}
+
+	log := slog.Default().Info
+	if to == BreakerOpen {
+		log = slog.Default().Warn
+	}
+	log("Circuit breaker state changed", slog.Attr{
+		Key:   "Server",
+		Value: slog.StringValue(s.URL.String()),
+	}, slog.Attr{
+		Key:   "from",
+		Value: slog.StringValue(string(from)),
+	}, slog.Attr{
+		Key:   "to",
+		Value: slog.StringValue(string(to)),
+	})
+}
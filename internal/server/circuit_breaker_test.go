@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newBreakerServer(breaker CircuitBreaker) *Server {
+	u, _ := url.Parse("http://localhost:8081")
+	s := NewServer(u, 1)
+	s.CircuitBreaker = breaker
+
+	return s
+}
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	s := newBreakerServer(CircuitBreaker{
+		Enabled:             true,
+		ConsecutiveFailures: 3,
+		Cooldown:            20 * time.Millisecond,
+		HalfOpenRequests:    2,
+	})
+
+	// A success resets the count
+	for _, success := range []bool{false, false, true, false, false} {
+		s.RecordResult(success)
+	}
+	if s.GetBreakerState() != BreakerClosed {
+		t.Fatalf("Expected breaker to be closed, got %s", s.GetBreakerState())
+	}
+	s.RecordResult(false)
+	if s.GetBreakerState() != BreakerOpen || s.AllowRequest() || s.IsAvailable() {
+		t.Fatalf("Expected open breaker to reject requests, got %s", s.GetBreakerState())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if s.GetBreakerState() != BreakerHalfOpen || !s.IsAvailable() {
+		t.Fatalf("Expected breaker to be half-open after the cooldown, got %s", s.GetBreakerState())
+	}
+	if !s.AllowRequest() || !s.AllowRequest() {
+		t.Fatalf("Expected half-open breaker to allow 2 trial requests")
+	}
+	if s.AllowRequest() || s.IsAvailable() {
+		t.Fatalf("Expected half-open breaker to reject requests after the trials")
+	}
+
+	s.RecordResult(true)
+	if s.GetBreakerState() != BreakerHalfOpen {
+		t.Fatalf("Expected breaker to stay half-open until every trial succeeded, got %s", s.GetBreakerState())
+	}
+	s.RecordResult(true)
+	if s.GetBreakerState() != BreakerClosed || !s.AllowRequest() {
+		t.Fatalf("Expected breaker to close after the trials succeeded, got %s", s.GetBreakerState())
+	}
+}
+
+func TestCircuitBreakerFailedTrialReopens(t *testing.T) {
+	s := newBreakerServer(CircuitBreaker{Enabled: true, ConsecutiveFailures: 1, Cooldown: 20 * time.Millisecond})
+
+	s.RecordResult(false)
+	time.Sleep(30 * time.Millisecond)
+	if !s.AllowRequest() {
+		t.Fatalf("Expected half-open breaker to allow a trial request")
+	}
+	s.RecordResult(false)
+	if s.GetBreakerState() != BreakerOpen {
+		t.Fatalf("Expected failed trial to open the breaker, got %s", s.GetBreakerState())
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	s := newBreakerServer(CircuitBreaker{Enabled: true, ErrorRatePercent: 50, MinRequests: 10})
+
+	// Failures never come in a row, but half of the requests fail
+	for i := 0; i < 9; i++ {
+		s.RecordResult(i%2 == 0)
+	}
+	if s.GetBreakerState() != BreakerClosed {
+		t.Fatalf("Expected breaker to stay closed below the min requests, got %s", s.GetBreakerState())
+	}
+	s.RecordResult(false)
+	if s.GetBreakerState() != BreakerOpen {
+		t.Fatalf("Expected breaker to open on the error rate, got %s", s.GetBreakerState())
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	s := newBreakerServer(CircuitBreaker{ConsecutiveFailures: 1})
+
+	s.RecordResult(false)
+	if s.GetBreakerState() != BreakerClosed || !s.AllowRequest() {
+		t.Fatalf("Expected disabled breaker to stay closed, got %s", s.GetBreakerState())
+	}
+}
+
+func TestCircuitBreakerReleaseRequest(t *testing.T) {
+	s := newBreakerServer(CircuitBreaker{Enabled: true, ConsecutiveFailures: 1, Cooldown: 20 * time.Millisecond, HalfOpenRequests: 1})
+	s.RecordResult(false)
+	time.Sleep(30 * time.Millisecond)
+
+	// A trial that ended without a result frees its slot for the next one
+	if !s.AllowRequest() || s.AllowRequest() {
+		t.Fatalf("Expected half-open breaker to allow 1 trial request")
+	}
+	s.ReleaseRequest()
+	if !s.AllowRequest() {
+		t.Fatalf("Expected the released trial slot to be available again")
+	}
+	s.RecordResult(true)
+	if s.GetBreakerState() != BreakerClosed {
+		t.Fatalf("Expected breaker to close after the trial succeeded, got %s", s.GetBreakerState())
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import "time"
+
+// SetDraining starts or stops draining the server. A draining server gets no new requests,
+// the ones in flight finish as usual. It returns true when a drain started.
+func (s *Server) SetDraining(draining bool) bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	started := draining && !s.Draining
+	if started {
+		s.DrainingSince = time.Now()
+		s.drained = false
+	}
+	if !draining {
+		s.DrainingSince = time.Time{}
+	}
+	s.Draining = draining
+
+	return started
+}
+
+func (s *Server) IsDraining() bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.Draining
+}
+
+// IsDrained reports whether the server is draining and its drain was reported as done
+func (s *Server) IsDrained() bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.Draining && s.drained
+}
+
+// MarkDrained marks the drain that started at since as done. It returns false when the server
+// isn't in that drain anymore or it was marked already, so every drain is reported once.
+func (s *Server) MarkDrained(since time.Time) bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	if !s.Draining || s.drained || !s.DrainingSince.Equal(since) {
+		return false
+	}
+	s.drained = true
+
+	return true
+}
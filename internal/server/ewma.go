@@ -0,0 +1,56 @@
+package server
+
+import (
+	"math"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// EWMA is a time decayed moving average of the response time, like Finagle's peak EWMA.
+// Old samples fade out with HalfLife, so a server that slows down is noticed quickly, and a
+// sample above the average is taken as is instead of being averaged, so spikes count at once.
+// HalfLife defaults to constants.DefaultLatencyHalfLife.
+type EWMA struct {
+	HalfLife time.Duration
+	value    float64
+	stamp    time.Time
+	samples  int
+}
+
+func (e *EWMA) Update(sample float64, now time.Time) {
+	decay := e.getDecay(now)
+	current := e.value * decay
+	if e.samples == 0 || sample > current {
+		e.value = sample
+	} else {
+		e.value = current + sample*(1-decay)
+	}
+	e.stamp = now
+	e.samples++
+}
+
+// Value returns the average decayed towards zero since the last sample, so a server that
+// stopped getting requests after a slow spell is tried again eventually
+func (e *EWMA) Value(now time.Time) float64 {
+	return e.value * e.getDecay(now)
+}
+
+// HasSamples reports whether anything was recorded yet
+func (e *EWMA) HasSamples() bool {
+	return e.samples > 0
+}
+
+// getDecay returns how much of the value is left at now, it halves every HalfLife
+func (e *EWMA) getDecay(now time.Time) float64 {
+	elapsed := now.Sub(e.stamp)
+	if e.samples == 0 || elapsed <= 0 {
+		return 1
+	}
+	halfLife := e.HalfLife
+	if halfLife <= 0 {
+		halfLife = constants.DefaultLatencyHalfLife
+	}
+
+	return math.Exp2(-float64(elapsed) / float64(halfLife))
+}
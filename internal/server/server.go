@@ -16,6 +16,22 @@ type Server struct {
 	ActiveConnections int
 	RequestsCount     int64
 	RequestsDuration  time.Duration
+	Latency           EWMA
+	// HealthySince is when the server last became healthy
+	HealthySince time.Time
+	SlowStart    SlowStart
+	// EjectedUntil is set by outlier detection, the server gets no traffic until then
+	EjectedUntil time.Time
+	// Ejections counts recent ejections, every repeat ejection lasts longer
+	Ejections      int
+	CircuitBreaker CircuitBreaker
+	breaker        breaker
+	// Disabled takes the server out of the pool until it is enabled again, whatever its health checks say
+	Disabled bool
+	// Draining servers get no new requests and finish the ones in flight
+	Draining      bool
+	DrainingSince time.Time
+	drained       bool
 }
 
 func NewServer(url *url.URL, weight int) *Server {
@@ -23,13 +39,107 @@ func NewServer(url *url.URL, weight int) *Server {
 		URL:               url,
 		Healthy:           true,
 		Weight:            weight,
-		CurrentWeight:     weight,
+		CurrentWeight:     0,
 		ActiveConnections: 0,
 		RequestsCount:     0,
 		RequestsDuration:  0,
+		HealthySince:      time.Now(),
 	}
 }
 
 func (s *Server) GetReverseProxy() *httputil.ReverseProxy {
-	return httputil.NewSingleHostReverseProxy(s.URL)
+	proxy := httputil.NewSingleHostReverseProxy(s.URL)
+	// Flush every write, so responses are streamed to the client as they arrive
+	proxy.FlushInterval = -1
+
+	return proxy
+}
+
+func (s *Server) IsHealthy() bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.Healthy
+}
+
+// IsAvailable reports whether the server can take requests, it is enabled, not draining,
+// healthy, not ejected and its circuit breaker lets requests through
+func (s *Server) IsAvailable() bool {
+	return s.IsAvailableAt(time.Now())
+}
+
+// IsAvailableAt is IsAvailable at the given time
+func (s *Server) IsAvailableAt(now time.Time) bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return !s.Draining && s.isAvailable(now)
+}
+
+// IsAvailableForSession is IsAvailable for clients that are pinned to the server,
+// a draining server keeps serving them
+func (s *Server) IsAvailableForSession() bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.isAvailable(time.Now())
+}
+
+// isAvailable must be called with s.Mut held
+func (s *Server) isAvailable(now time.Time) bool {
+	return !s.Disabled && s.Healthy && !now.Before(s.EjectedUntil) && s.breakerAvailable(now)
+}
+
+func (s *Server) IsEjected() bool {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return time.Now().Before(s.EjectedUntil)
+}
+
+// SetHealthy marks the server as healthy or not, HealthySince is reset when it recovers
+func (s *Server) SetHealthy(healthy bool) {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	if healthy && !s.Healthy {
+		s.HealthySince = time.Now()
+	}
+	s.Healthy = healthy
+}
+
+// MarkUp marks the server as healthy and ends its ejection, the health checks take over again on their next probe
+func (s *Server) MarkUp() {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	if !s.Healthy {
+		s.HealthySince = time.Now()
+	}
+	s.Healthy = true
+	s.EjectedUntil = time.Time{}
+}
+
+func (s *Server) GetCurrentWeight() int {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.CurrentWeight
+}
+
+// UpdateStats records a finished request and how long the server took to answer it
+func (s *Server) UpdateStats(elapsed time.Duration) {
+	s.Mut.Lock()
+	s.RequestsCount++
+	s.RequestsDuration += elapsed
+	s.Latency.Update(float64(elapsed), time.Now())
+	s.Mut.Unlock()
+}
+
+// GetLatency returns the moving average of the response time, ok is false until a request finished
+func (s *Server) GetLatency() (latency time.Duration, ok bool) {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return time.Duration(s.Latency.Value(time.Now())), s.Latency.HasSamples()
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"math"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// SlowStart ramps up the traffic a server gets after it is added or recovers,
+// so services with cold caches aren't overwhelmed by their full share at once
+type SlowStart struct {
+	Duration time.Duration
+	// Curve shapes the ramp, 1 is linear, bigger values start slower and smaller values faster
+	Curve float64
+	// MinWeightPercent is the share of the weight the ramp starts from
+	MinWeightPercent float64
+}
+
+// GetSlowStartFactor returns the part of its weight the server currently gets, between
+// the slow start minimum and 1, which is reached once the slow start duration has passed
+func (s *Server) GetSlowStartFactor() float64 {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return s.getSlowStartFactor(time.Now())
+}
+
+// EffectiveWeight returns the weight of the server scaled by its slow start ramp
+func (s *Server) EffectiveWeight() float64 {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	return float64(max(s.Weight, 1)) * s.getSlowStartFactor(time.Now())
+}
+
+func (s *Server) getSlowStartFactor(now time.Time) float64 {
+	slowStart := s.SlowStart
+	elapsed := now.Sub(s.HealthySince)
+	if slowStart.Duration <= 0 || elapsed >= slowStart.Duration {
+		return 1
+	}
+
+	curve := slowStart.Curve
+	if curve <= 0 {
+		curve = constants.DefaultSlowStartCurve
+	}
+	minWeightPercent := slowStart.MinWeightPercent
+	if minWeightPercent <= 0 {
+		minWeightPercent = constants.DefaultSlowStartMinWeightPercent
+	}
+	progress := math.Max(float64(elapsed), 0) / float64(slowStart.Duration)
+
+	return math.Max(minWeightPercent/100, math.Pow(progress, curve))
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGetSlowStartFactor(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		id        int
+		slowStart SlowStart
+		elapsed   time.Duration
+		expected  float64
+	}{
+		{id: 1, slowStart: SlowStart{}, elapsed: 0, expected: 1},
+		{id: 2, slowStart: SlowStart{Duration: 10 * time.Second}, elapsed: 0, expected: 0.1},
+		{id: 3, slowStart: SlowStart{Duration: 10 * time.Second}, elapsed: 5 * time.Second, expected: 0.5},
+		{id: 4, slowStart: SlowStart{Duration: 10 * time.Second}, elapsed: 10 * time.Second, expected: 1},
+		{id: 5, slowStart: SlowStart{Duration: 10 * time.Second, Curve: 2}, elapsed: 5 * time.Second, expected: 0.25},
+		{id: 6, slowStart: SlowStart{Duration: 10 * time.Second, Curve: 0.5}, elapsed: 4 * time.Second, expected: 0.63},
+		{id: 7, slowStart: SlowStart{Duration: 10 * time.Second, MinWeightPercent: 50}, elapsed: 2 * time.Second, expected: 0.5},
+	}
+
+	for _, tc := range testCases {
+		s := &Server{Weight: 4, HealthySince: now.Add(-tc.elapsed), SlowStart: tc.slowStart}
+		factor := s.getSlowStartFactor(now)
+		if math.Abs(factor-tc.expected) > 0.01 {
+			t.Fatalf("Test case %d: Expected factor %.2f, got %.2f", tc.id, tc.expected, factor)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.ConsistentHashing, func(opts Options) Strategy {
+		return &ConsistentHashing{VirtualNodes: opts.VirtualNodes, HashKey: opts.HashKey}
+	})
+}
+
+type ringNode struct {
+	hash   uint64
+	server *server.Server
+}
+
+type poolMember struct {
+	server *server.Server
+	weight int
+}
+
+// ConsistentHashing places every server on a hash ring multiple times (virtual nodes),
+// and sends a request to the first server clockwise from the hash of the client.
+// When a server is added or removed only about 1/N of the clients move to another server.
+type ConsistentHashing struct {
+	Mut          sync.Mutex
+	VirtualNodes int
+	HashKey      KeyFunc
+	ring         []ringNode
+	members      []poolMember
+}
+
+func (ch *ConsistentHashing) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	ch.Mut.Lock()
+	defer ch.Mut.Unlock()
+
+	if len(pool) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+	if members := getPoolMembers(pool); !slices.Equal(members, ch.members) {
+		ch.buildRing(members)
+	}
+
+	hash := hash64(getHashKey(req, ch.HashKey))
+	idx, _ := slices.BinarySearchFunc(ch.ring, hash, func(n ringNode, h uint64) int {
+		return cmp.Compare(n.hash, h)
+	})
+
+	// Walk the ring clockwise until an available server is found
+	checked := make(map[*server.Server]bool, len(pool))
+	for i := 0; i < len(ch.ring) && len(checked) < len(pool); i++ {
+		node := ch.ring[(idx+i)%len(ch.ring)]
+		if checked[node.server] {
+			continue
+		}
+		if isAvailable(req, node.server) {
+			return node.server, nil
+		}
+		checked[node.server] = true
+	}
+
+	return nil, ErrNoHealthyServers
+}
+
+func (ch *ConsistentHashing) buildRing(members []poolMember) {
+	virtualNodes := ch.VirtualNodes
+	if virtualNodes <= 0 {
+		virtualNodes = constants.DefaultVirtualNodes
+	}
+
+	ring := make([]ringNode, 0)
+	for _, m := range members {
+		for i := 0; i < virtualNodes*m.weight; i++ {
+			ring = append(ring, ringNode{
+				hash:   hash64(fmt.Sprintf("%s#%d", m.server.URL.String(), i)),
+				server: m.server,
+			})
+		}
+	}
+	slices.SortFunc(ring, func(a, b ringNode) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+
+	ch.ring = ring
+	ch.members = members
+}
+
+// getPoolMembers returns the servers in the pool with their weights, servers without a weight count as 1
+func getPoolMembers(pool []*server.Server) []poolMember {
+	members := make([]poolMember, 0, len(pool))
+	for _, s := range pool {
+		s.Mut.Lock()
+		weight := s.Weight
+		s.Mut.Unlock()
+		if weight < 1 {
+			weight = 1
+		}
+		members = append(members, poolMember{server: s, weight: weight})
+	}
+
+	return members
+}
@@ -0,0 +1,115 @@
+package strategy
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func newPool(n int) []*server.Server {
+	pool := make([]*server.Server, 0, n)
+	for i := 0; i < n; i++ {
+		pool = append(pool, server.NewServer(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", 8080+i)}, 1))
+	}
+
+	return pool
+}
+
+func TestConsistentHashingIgnoresClientPort(t *testing.T) {
+	pool := newPool(5)
+	s := &ConsistentHashing{}
+
+	expected, err := s.Next(newRequest("10.0.0.1:50000"), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	for port := 50001; port < 50010; port++ {
+		server, err := s.Next(newRequest(fmt.Sprintf("10.0.0.1:%d", port)), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != expected {
+			t.Fatalf("Expected port %d to go to %s, got %s", port, expected.URL.Host, server.URL.Host)
+		}
+	}
+}
+
+func TestConsistentHashingMinimalDisruption(t *testing.T) {
+	pool := newPool(5)
+	s := &ConsistentHashing{}
+	clients := 10000
+
+	before := make([]*server.Server, clients)
+	for i := 0; i < clients; i++ {
+		before[i], _ = s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+	}
+
+	// Adding a sixth server should only move about 1/6 of the clients, all of them to the new server
+	pool = append(pool, server.NewServer(&url.URL{Scheme: "http", Host: "localhost:9090"}, 1))
+	moved := 0
+	for i := 0; i < clients; i++ {
+		after, _ := s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+		if after != before[i] {
+			moved++
+			if after != pool[5] {
+				t.Fatalf("Expected client %d to move to the new server, got %s", i, after.URL.Host)
+			}
+		}
+	}
+
+	if moved < clients/10 || moved > clients/4 {
+		t.Fatalf("Expected about %d clients to move, got %d", clients/6, moved)
+	}
+}
+
+func TestConsistentHashingUnhealthyServer(t *testing.T) {
+	pool := newPool(3)
+	s := &ConsistentHashing{}
+
+	req := newRequest("10.0.0.1:1234")
+	first, err := s.Next(req, pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+
+	first.Healthy = false
+	second, err := s.Next(req, pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if second == first {
+		t.Fatalf("Expected unhealthy server %s to be skipped", first.URL.Host)
+	}
+
+	first.Healthy = true
+	third, _ := s.Next(req, pool)
+	if third != first {
+		t.Fatalf("Expected client to go back to %s once it is healthy, got %s", first.URL.Host, third.URL.Host)
+	}
+
+	for _, server := range pool {
+		server.Healthy = false
+	}
+	if _, err := s.Next(req, pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
+
+func TestConsistentHashingWeights(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Weight = 3
+	s := &ConsistentHashing{}
+
+	counts := map[*server.Server]int{}
+	for i := 0; i < 10000; i++ {
+		server, _ := s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+		counts[server]++
+	}
+
+	// A server with weight 3 should get roughly 75% of the clients
+	if counts[pool[0]] < 6500 || counts[pool[0]] > 8500 {
+		t.Fatalf("Expected about 7500 clients on the weighted server, got %d", counts[pool[0]])
+	}
+}
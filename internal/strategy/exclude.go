@@ -0,0 +1,35 @@
+package strategy
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+type excludedKey struct{}
+
+// WithExcluded returns a copy of the request for which strategies skip the given servers,
+// as if they were unhealthy, on top of the servers that were already excluded
+func WithExcluded(req *http.Request, servers ...*server.Server) *http.Request {
+	excluded, _ := req.Context().Value(excludedKey{}).([]*server.Server)
+	excluded = append(slices.Clip(excluded), servers...)
+
+	return req.WithContext(context.WithValue(req.Context(), excludedKey{}, excluded))
+}
+
+// IsExcluded reports whether the request already failed on the server, so strategies should skip it
+func IsExcluded(req *http.Request, s *server.Server) bool {
+	if req == nil {
+		return false
+	}
+	excluded, _ := req.Context().Value(excludedKey{}).([]*server.Server)
+
+	return slices.Contains(excluded, s)
+}
+
+// isAvailable reports whether the server can take the request, it is healthy, not ejected and not excluded
+func isAvailable(req *http.Request, s *server.Server) bool {
+	return s.IsAvailable() && !IsExcluded(req, s)
+}
@@ -0,0 +1,47 @@
+package strategy
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+)
+
+// KeyFunc extracts the key that hash based strategies use to pick a server for a request
+type KeyFunc func(req *http.Request) string
+
+// getHashKey returns the key for the request, the client IP is used when no KeyFunc is set
+func getHashKey(req *http.Request, keyFunc KeyFunc) string {
+	if keyFunc == nil {
+		return getClientIP(req)
+	}
+
+	return keyFunc(req)
+}
+
+// getClientIP returns the IP address of the client without the ephemeral port,
+// so all requests from the same client hash the same way
+func getClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// hash64 returns a well distributed 64 bit hash of the key.
+// FNV-1a alone clusters similar keys like "server-1" and "server-2", so the result is run
+// through the murmur3 finalizer to spread them out.
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,161 @@
+package strategy
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+// keyPart extracts one piece of the hash key, it returns false when the request doesn't carry it
+type keyPart func(req *http.Request) (string, bool)
+
+// NewKeyFunc builds the KeyFunc described by source. The source is either a single part:
+//
+//	client-ip, path, header:<name>, cookie:<name> or query:<name>
+//
+// or a template combining them, e.g. "{header:X-Tenant}/{cookie:session}".
+// When a part is missing from the request, the fallback is used as the key instead.
+func NewKeyFunc(source string, fallback constants.HashKeyFallback, trustForwardedFor bool) (KeyFunc, error) {
+	clientIP := func(req *http.Request) string {
+		if trustForwardedFor {
+			if ip, ok := getForwardedFor(req); ok {
+				return ip
+			}
+		}
+
+		return getClientIP(req)
+	}
+
+	var fallbackFunc KeyFunc
+	switch fallback {
+	case "", constants.ClientIPFallback:
+		fallbackFunc = clientIP
+	case constants.RandomFallback:
+		fallbackFunc = func(_ *http.Request) string {
+			return strconv.FormatUint(rand.Uint64(), 16)
+		}
+	default:
+		return nil, fmt.Errorf("invalid hash key fallback %s", fallback)
+	}
+
+	if source == "" {
+		source = string(constants.ClientIPHashKey)
+	}
+	literals, parts, err := parseKeyTemplate(source, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request) string {
+		var key strings.Builder
+		for i, part := range parts {
+			value, ok := part(req)
+			if !ok {
+				return fallbackFunc(req)
+			}
+			key.WriteString(literals[i])
+			key.WriteString(value)
+		}
+		key.WriteString(literals[len(parts)])
+
+		return key.String()
+	}, nil
+}
+
+// parseKeyTemplate splits the source into literal text and parts, literals[i] comes before parts[i]
+func parseKeyTemplate(source string, clientIP KeyFunc) ([]string, []keyPart, error) {
+	if !strings.Contains(source, "{") {
+		part, err := parseKeyPart(source, clientIP)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []string{"", ""}, []keyPart{part}, nil
+	}
+
+	literals := []string{}
+	parts := []keyPart{}
+	rest := source
+	for {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return nil, nil, fmt.Errorf("unclosed { in hash key %s", source)
+		}
+		part, err := parseKeyPart(rest[start+1:start+end], clientIP)
+		if err != nil {
+			return nil, nil, err
+		}
+		literals = append(literals, rest[:start])
+		parts = append(parts, part)
+		rest = rest[start+end+1:]
+	}
+	literals = append(literals, rest)
+
+	return literals, parts, nil
+}
+
+func parseKeyPart(spec string, clientIP KeyFunc) (keyPart, error) {
+	kind, name, _ := strings.Cut(spec, ":")
+	switch constants.HashKeySource(kind) {
+	case constants.ClientIPHashKey:
+		return func(req *http.Request) (string, bool) {
+			return clientIP(req), true
+		}, nil
+	case constants.PathHashKey:
+		return func(req *http.Request) (string, bool) {
+			return req.URL.Path, true
+		}, nil
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("hash key %s is missing a name", spec)
+	}
+	switch constants.HashKeySource(kind) {
+	case constants.HeaderHashKey:
+		return func(req *http.Request) (string, bool) {
+			value := req.Header.Get(name)
+			return value, value != ""
+		}, nil
+	case constants.CookieHashKey:
+		return func(req *http.Request) (string, bool) {
+			cookie, err := req.Cookie(name)
+			if err != nil || cookie.Value == "" {
+				return "", false
+			}
+			return cookie.Value, true
+		}, nil
+	case constants.QueryHashKey:
+		return func(req *http.Request) (string, bool) {
+			value := req.URL.Query().Get(name)
+			return value, value != ""
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid hash key %s", spec)
+}
+
+// getForwardedFor returns the original client from the X-Forwarded-For header,
+// which is the first address in the list
+func getForwardedFor(req *http.Request) (string, bool) {
+	forwardedFor := req.Header.Get("X-Forwarded-For")
+	if forwardedFor == "" {
+		return "", false
+	}
+
+	first, _, _ := strings.Cut(forwardedFor, ",")
+	first = strings.TrimSpace(first)
+	if host, _, err := net.SplitHostPort(first); err == nil {
+		first = host
+	}
+
+	return first, first != ""
+}
@@ -0,0 +1,77 @@
+package strategy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+)
+
+func TestNewKeyFunc(t *testing.T) {
+	req := newRequest("10.0.0.1:1234")
+	req.URL.Path = "/users/42"
+	req.URL.RawQuery = "user=bob"
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	testCases := []struct {
+		source            string
+		trustForwardedFor bool
+		expectedKey       string
+	}{
+		{source: "", expectedKey: "10.0.0.1"},
+		{source: "client-ip", expectedKey: "10.0.0.1"},
+		{source: "client-ip", trustForwardedFor: true, expectedKey: "203.0.113.7"},
+		{source: "header:X-Tenant", expectedKey: "acme"},
+		{source: "cookie:session", expectedKey: "abc"},
+		{source: "query:user", expectedKey: "bob"},
+		{source: "path", expectedKey: "/users/42"},
+		{source: "{header:X-Tenant}/{cookie:session}", expectedKey: "acme/abc"},
+		{source: "tenant-{header:X-Tenant}-{path}!", expectedKey: "tenant-acme-/users/42!"},
+	}
+
+	for i, tc := range testCases {
+		keyFunc, err := NewKeyFunc(tc.source, constants.ClientIPFallback, tc.trustForwardedFor)
+		if err != nil {
+			t.Fatalf("Test case %d: Error creating key func: %s", i, err.Error())
+		}
+		if key := keyFunc(req); key != tc.expectedKey {
+			t.Fatalf("Test case %d: Expected key %q, got %q", i, tc.expectedKey, key)
+		}
+	}
+}
+
+func TestNewKeyFuncFallback(t *testing.T) {
+	req := newRequest("10.0.0.1:1234")
+
+	keyFunc, _ := NewKeyFunc("{header:X-Tenant}/{path}", constants.ClientIPFallback, false)
+	if key := keyFunc(req); key != "10.0.0.1" {
+		t.Fatalf("Expected missing header to fall back to the client IP, got %q", key)
+	}
+
+	keyFunc, _ = NewKeyFunc("cookie:session", constants.RandomFallback, false)
+	if keyFunc(req) == keyFunc(req) {
+		t.Fatalf("Expected missing cookie to fall back to a random key")
+	}
+}
+
+func TestNewKeyFuncInvalid(t *testing.T) {
+	testCases := []struct {
+		source   string
+		fallback constants.HashKeyFallback
+	}{
+		{source: "invalid"},
+		{source: "header"},
+		{source: "cookie:"},
+		{source: "{header:X-Tenant"},
+		{source: "{invalid}"},
+		{source: "path", fallback: "invalid"},
+	}
+
+	for i, tc := range testCases {
+		if _, err := NewKeyFunc(tc.source, tc.fallback, false); err == nil {
+			t.Fatalf("Test case %d: Expected error for hash key %q", i, tc.source)
+		}
+	}
+}
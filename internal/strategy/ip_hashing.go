@@ -0,0 +1,51 @@
+package strategy
+
+import (
+	"hash/fnv"
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.IPHashing, func(opts Options) Strategy {
+		return &IPHashing{HashKey: opts.HashKey}
+	})
+}
+
+type IPHashing struct {
+	HashKey KeyFunc
+}
+
+func (ih *IPHashing) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	if len(pool) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(getHashKey(req, ih.HashKey)))
+	hashedIP := hash.Sum32()
+
+	idx := int(hashedIP) % len(pool)
+	server := pool[idx]
+
+	if !isAvailable(req, server) {
+		for i := 0; i < len(pool)-1; i++ {
+			idx++
+			if idx >= len(pool) {
+				idx = 0
+			}
+			server = pool[idx]
+			if isAvailable(req, server) {
+				break
+			}
+		}
+
+		if !isAvailable(req, server) {
+			return nil, ErrNoHealthyServers
+		}
+	}
+
+	return server, nil
+}
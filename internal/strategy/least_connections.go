@@ -0,0 +1,38 @@
+package strategy
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.LeastConnections, func(_ Options) Strategy {
+		return &LeastConnections{}
+	})
+}
+
+type LeastConnections struct{}
+
+func (lc *LeastConnections) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	minActiveConnections := math.MaxInt32
+	idx := -1
+	for i := 0; i < len(pool); i++ {
+		pool[i].Mut.Lock()
+		activeConnections := pool[i].ActiveConnections
+		pool[i].Mut.Unlock()
+		healthy := isAvailable(req, pool[i])
+
+		if activeConnections < minActiveConnections && healthy {
+			minActiveConnections = activeConnections
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return nil, ErrNoHealthyServers
+	}
+
+	return pool[idx], nil
+}
@@ -0,0 +1,148 @@
+package strategy
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.LeastResponseTime, func(opts Options) Strategy {
+		return &LeastResponseTime{
+			HalfLife:  opts.LatencyHalfLife,
+			Probation: opts.LatencyProbation,
+		}
+	})
+}
+
+// LeastResponseTime picks the server with the lowest cost, which is a peak EWMA of its response
+// time multiplied by the requests it has in flight, like Finagle's peak EWMA balancer.
+// Old response times fade out with HalfLife, see server.EWMA.
+// New and recovered servers are on probation, during which they are assumed to be as fast as the
+// rest of the pool, so they aren't flooded before their own response times are known.
+type LeastResponseTime struct {
+	Mut       sync.Mutex
+	HalfLife  time.Duration
+	Probation time.Duration
+	latencies map[*server.Server]*server.EWMA
+	now       func() time.Time
+}
+
+func (lrt *LeastResponseTime) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	lrt.Mut.Lock()
+	defer lrt.Mut.Unlock()
+
+	now := lrt.getNow()
+	latencies := make([]float64, len(pool))
+	onProbation := make([]bool, len(pool))
+	activeConnections := make([]int, len(pool))
+	healthy := make([]bool, len(pool))
+	poolLatency, poolCount := 0.0, 0
+	known := 0
+	for i, s := range pool {
+		s.Mut.Lock()
+		activeConnections[i] = s.ActiveConnections
+		healthySince := s.HealthySince
+		s.Mut.Unlock()
+		healthy[i] = isAvailable(req, s)
+
+		latency, ok := lrt.latencies[s]
+		if ok {
+			latencies[i] = latency.Value(now)
+			known++
+		}
+		onProbation[i] = !ok || now.Sub(healthySince) < lrt.getProbation()
+		if healthy[i] && !onProbation[i] {
+			poolLatency += latencies[i]
+			poolCount++
+		}
+	}
+	if poolCount > 0 {
+		poolLatency /= float64(poolCount)
+	}
+	// Some servers were removed from the pool by a reload
+	if known < len(lrt.latencies) {
+		lrt.prune(pool)
+	}
+
+	leastCost := math.Inf(1)
+	leastCostServer := -1
+	for i := range pool {
+		if !healthy[i] {
+			continue
+		}
+
+		latency := latencies[i]
+		if onProbation[i] && poolCount > 0 {
+			latency = poolLatency
+		}
+		cost := latency * float64(activeConnections[i]+1)
+		// Nothing is known about the latency yet, so fall back to the requests in flight
+		if latency == 0 {
+			cost = float64(activeConnections[i])
+		}
+		if cost < leastCost {
+			leastCost = cost
+			leastCostServer = i
+		}
+	}
+
+	if leastCostServer == -1 {
+		return nil, ErrNoHealthyServers
+	}
+
+	return pool[leastCostServer], nil
+}
+
+func (lrt *LeastResponseTime) OnRequestFinish(s *server.Server, elapsed time.Duration) {
+	lrt.Mut.Lock()
+	defer lrt.Mut.Unlock()
+
+	if lrt.latencies == nil {
+		lrt.latencies = map[*server.Server]*server.EWMA{}
+	}
+	latency, ok := lrt.latencies[s]
+	if !ok {
+		latency = &server.EWMA{HalfLife: lrt.getHalfLife()}
+		lrt.latencies[s] = latency
+	}
+	latency.Update(float64(elapsed), lrt.getNow())
+}
+
+// prune forgets the response times of servers that are no longer in the pool
+func (lrt *LeastResponseTime) prune(pool []*server.Server) {
+	for s := range lrt.latencies {
+		if !slices.Contains(pool, s) {
+			delete(lrt.latencies, s)
+		}
+	}
+}
+
+func (lrt *LeastResponseTime) getHalfLife() time.Duration {
+	if lrt.HalfLife > 0 {
+		return lrt.HalfLife
+	}
+
+	return constants.DefaultLatencyHalfLife
+}
+
+func (lrt *LeastResponseTime) getProbation() time.Duration {
+	if lrt.Probation > 0 {
+		return lrt.Probation
+	}
+
+	return constants.DefaultLatencyProbation
+}
+
+func (lrt *LeastResponseTime) getNow() time.Time {
+	if lrt.now != nil {
+		return lrt.now()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,102 @@
+package strategy
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.Maglev, func(opts Options) Strategy {
+		return &Maglev{HashKey: opts.HashKey}
+	})
+}
+
+// Maglev implements the lookup table from Google's Maglev load balancer.
+// Every server fills the slots of a fixed size table following its own permutation,
+// which gives near perfect balance, minimal disruption when the pool changes and O(1) lookups.
+// Servers with a higher weight take more turns when the table is populated.
+// The table only depends on the pool and the weights, unavailable servers hand their slots
+// to the next server in the table, so health changes don't rebuild it.
+type Maglev struct {
+	Mut     sync.Mutex
+	HashKey KeyFunc
+	table   []*server.Server
+	members []poolMember
+	pool    []*server.Server
+}
+
+func (m *Maglev) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	m.Mut.Lock()
+	defer m.Mut.Unlock()
+
+	if len(pool) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+	// Every change of the servers or their weights replaces the pool, so the members
+	// only have to be compared when a new pool comes in
+	if !isSamePool(pool, m.pool) {
+		if members := getPoolMembers(pool); !slices.Equal(members, m.members) {
+			m.buildTable(members)
+		}
+		m.pool = pool
+	}
+
+	hash := hash64(getHashKey(req, m.HashKey))
+	size := uint64(len(m.table))
+	idx := hash % size
+	var checked map[*server.Server]bool
+	for i := uint64(0); i < size && len(checked) < len(m.members); i++ {
+		s := m.table[(idx+i)%size]
+		if isAvailable(req, s) {
+			return s, nil
+		}
+		if checked == nil {
+			checked = make(map[*server.Server]bool, len(m.members))
+		}
+		checked[s] = true
+	}
+
+	return nil, ErrNoHealthyServers
+}
+
+func (m *Maglev) buildTable(members []poolMember) {
+	size := uint64(constants.DefaultMaglevTableSize)
+	offsets := make([]uint64, len(members))
+	skips := make([]uint64, len(members))
+	next := make([]uint64, len(members))
+	for i, member := range members {
+		name := member.server.URL.String()
+		offsets[i] = hash64(name+"#offset") % size
+		skips[i] = hash64(name+"#skip")%(size-1) + 1
+	}
+
+	table := make([]*server.Server, size)
+	filled := uint64(0)
+	for filled < size {
+		for i, member := range members {
+			for turn := 0; turn < member.weight && filled < size; turn++ {
+				// Find the next preferred slot of the server that is still empty
+				slot := (offsets[i] + next[i]*skips[i]) % size
+				for table[slot] != nil {
+					next[i]++
+					slot = (offsets[i] + next[i]*skips[i]) % size
+				}
+				table[slot] = member.server
+				next[i]++
+				filled++
+			}
+		}
+	}
+
+	m.table = table
+	m.members = members
+}
+
+// isSamePool reports whether a and b are the same slice, not just equal
+func isSamePool(a []*server.Server, b []*server.Server) bool {
+	return len(a) == len(b) && len(a) > 0 && &a[0] == &b[0]
+}
@@ -0,0 +1,107 @@
+package strategy
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func TestMaglevBalance(t *testing.T) {
+	pool := newPool(5)
+	s := &Maglev{}
+	s.Next(newRequest(ip), pool)
+
+	counts := map[*server.Server]int{}
+	for _, server := range s.table {
+		counts[server]++
+	}
+
+	// Every server should own almost exactly 1/5 of the table
+	expected := len(s.table) / len(pool)
+	for _, server := range pool {
+		if counts[server] < expected*95/100 || counts[server] > expected*105/100 {
+			t.Fatalf("Expected about %d slots for %s, got %d", expected, server.URL.Host, counts[server])
+		}
+	}
+}
+
+func TestMaglevMinimalDisruption(t *testing.T) {
+	pool := newPool(5)
+	s := &Maglev{}
+	clients := 10000
+
+	before := make([]*server.Server, clients)
+	for i := 0; i < clients; i++ {
+		before[i], _ = s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+	}
+
+	// Taking one server out should mostly move the clients it owned
+	pool[2].Healthy = false
+	moved := 0
+	for i := 0; i < clients; i++ {
+		after, _ := s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+		if after == pool[2] {
+			t.Fatalf("Expected unhealthy server to be skipped for client %d", i)
+		}
+		if after != before[i] {
+			moved++
+		}
+	}
+
+	if moved > clients*30/100 {
+		t.Fatalf("Expected about %d clients to move, got %d", clients/5, moved)
+	}
+}
+
+func TestMaglevKeepsTableOnHealthChange(t *testing.T) {
+	pool := newPool(3)
+	s := &Maglev{}
+	s.Next(newRequest(ip), pool)
+	table := s.table
+
+	pool[0].Healthy = false
+	s.Next(newRequest(ip), pool)
+	if &s.table[0] != &table[0] {
+		t.Fatalf("Expected the table to be kept when a server becomes unavailable")
+	}
+
+	// A reload replaces the pool, which rebuilds the table when the weights changed
+	next := []*server.Server{pool[0], pool[1], pool[2]}
+	pool[1].Weight = 2
+	s.Next(newRequest(ip), next)
+	if &s.table[0] == &table[0] {
+		t.Fatalf("Expected the table to be rebuilt for the new weights")
+	}
+}
+
+func TestMaglevWeights(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Scheme: "http", Host: "localhost:8080"}, 3),
+		server.NewServer(&url.URL{Scheme: "http", Host: "localhost:8081"}, 1),
+	}
+	s := &Maglev{}
+	s.Next(newRequest(ip), pool)
+
+	counts := map[*server.Server]int{}
+	for _, server := range s.table {
+		counts[server]++
+	}
+
+	expected := len(s.table) * 3 / 4
+	if counts[pool[0]] < expected*95/100 || counts[pool[0]] > expected*105/100 {
+		t.Fatalf("Expected about %d slots for the weighted server, got %d", expected, counts[pool[0]])
+	}
+}
+
+func TestMaglevNoHealthyServers(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Healthy = false
+	pool[1].Healthy = false
+	s := &Maglev{}
+
+	if _, err := s.Next(newRequest(ip), pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
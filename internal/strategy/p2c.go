@@ -0,0 +1,170 @@
+package strategy
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+// Number of random draws before P2C gives up on sampling and scans the pool for healthy servers
+const p2cMaxDraws = 16
+
+func init() {
+	Register(constants.P2C, func(_ Options) Strategy {
+		return &P2C{}
+	})
+	Register(constants.P2CLatency, func(_ Options) Strategy {
+		return &P2C{UseLatency: true}
+	})
+}
+
+// P2C implements the power of two choices. It samples two random healthy servers
+// and picks the less loaded one, which avoids herding onto a single server when
+// many requests arrive at once and doesn't need to scan the whole pool.
+// Servers are sampled proportionally to their weight and their load is divided by it.
+// With UseLatency the load is also multiplied by the moving average of the response time,
+// servers without one count as the average of the pool.
+type P2C struct {
+	Mut        sync.Mutex
+	UseLatency bool
+	maxWeight  int
+}
+
+func (p *P2C) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	first, second := p.sample(req, pool)
+	if first == nil {
+		return nil, ErrNoHealthyServers
+	}
+	if second == nil {
+		return first, nil
+	}
+
+	firstLoad, secondLoad := getLoad(first), getLoad(second)
+	if p.UseLatency {
+		firstLatency, firstOk := first.GetLatency()
+		secondLatency, secondOk := second.GetLatency()
+		// Servers without a response time yet count as average, so they aren't flooded
+		// before their first responses come back
+		if !firstOk || !secondOk {
+			average := getAverageLatency(req, pool)
+			if !firstOk {
+				firstLatency = average
+			}
+			if !secondOk {
+				secondLatency = average
+			}
+		}
+		if firstLatency > 0 && secondLatency > 0 {
+			firstLoad *= float64(firstLatency)
+			secondLoad *= float64(secondLatency)
+		}
+	}
+	if secondLoad < firstLoad || (secondLoad == firstLoad && rand.Intn(2) == 0) {
+		return second, nil
+	}
+
+	return first, nil
+}
+
+// sample draws two distinct available servers, the second one is nil when only one server is available
+func (p *P2C) sample(req *http.Request, pool []*server.Server) (*server.Server, *server.Server) {
+	if len(pool) == 0 {
+		return nil, nil
+	}
+
+	var first *server.Server
+	for i := 0; i < p2cMaxDraws; i++ {
+		s := p.draw(pool)
+		if s == nil || s == first || !isAvailable(req, s) {
+			continue
+		}
+		if first == nil {
+			first = s
+			continue
+		}
+
+		return first, s
+	}
+
+	// Most of the pool is unhealthy, fall back to picking from the healthy servers
+	healthyServers := make([]*server.Server, 0)
+	for _, s := range pool {
+		if isAvailable(req, s) {
+			healthyServers = append(healthyServers, s)
+		}
+	}
+	switch len(healthyServers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return healthyServers[0], nil
+	}
+	perm := rand.Perm(len(healthyServers))
+
+	return healthyServers[perm[0]], healthyServers[perm[1]]
+}
+
+// draw picks a random server with a probability proportional to its weight, using rejection
+// sampling against the biggest weight seen so far, so the pool never has to be scanned
+func (p *P2C) draw(pool []*server.Server) *server.Server {
+	s := pool[rand.Intn(len(pool))]
+	weight := getWeight(s)
+
+	p.Mut.Lock()
+	if weight > p.maxWeight {
+		p.maxWeight = weight
+	}
+	maxWeight := p.maxWeight
+	p.Mut.Unlock()
+
+	if rand.Intn(maxWeight) >= weight {
+		return nil
+	}
+
+	return s
+}
+
+func getLoad(s *server.Server) float64 {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	weight := s.Weight
+	if weight < 1 {
+		weight = 1
+	}
+
+	return float64(s.ActiveConnections+1) / float64(weight)
+}
+
+// getAverageLatency returns the average response time of the available servers that have one
+func getAverageLatency(req *http.Request, pool []*server.Server) time.Duration {
+	var total time.Duration
+	count := 0
+	for _, s := range pool {
+		if latency, ok := s.GetLatency(); ok && isAvailable(req, s) {
+			total += latency
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	return total / time.Duration(count)
+}
+
+// getWeight returns the weight of the server, servers without a weight count as 1
+func getWeight(s *server.Server) int {
+	s.Mut.Lock()
+	defer s.Mut.Unlock()
+
+	if s.Weight < 1 {
+		return 1
+	}
+
+	return s.Weight
+}
@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestP2CPicksLessLoadedServer(t *testing.T) {
+	pool := newPool(2)
+	pool[0].ActiveConnections = 5
+	pool[1].ActiveConnections = 1
+	s := &P2C{}
+
+	// With two servers both are always sampled, so the less loaded one always wins
+	for i := 0; i < 20; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != pool[1] {
+			t.Fatalf("Test case %d: Expected less loaded server %s, got %s", i, pool[1].URL.Host, server.URL.Host)
+		}
+	}
+}
+
+func TestP2CSpreadsLoad(t *testing.T) {
+	pool := newPool(10)
+	s := &P2C{}
+
+	// Simulate requests that never finish, the load should stay even
+	for i := 0; i < 1000; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		server.ActiveConnections++
+	}
+
+	for _, server := range pool {
+		if server.ActiveConnections < 90 || server.ActiveConnections > 110 {
+			t.Fatalf("Expected about 100 requests on %s, got %d", server.URL.Host, server.ActiveConnections)
+		}
+	}
+}
+
+func TestP2CWeights(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Weight = 3
+	s := &P2C{}
+
+	for i := 0; i < 400; i++ {
+		server, _ := s.Next(newRequest(ip), pool)
+		server.ActiveConnections++
+	}
+
+	// Load is divided by weight, so the weighted server takes about 3 times the connections
+	if pool[0].ActiveConnections < 280 || pool[0].ActiveConnections > 320 {
+		t.Fatalf("Expected about 300 requests on the weighted server, got %d", pool[0].ActiveConnections)
+	}
+}
+
+func TestP2CUnhealthyServers(t *testing.T) {
+	pool := newPool(5)
+	for _, server := range pool[:4] {
+		server.Healthy = false
+	}
+	s := &P2C{}
+
+	for i := 0; i < 10; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != pool[4] {
+			t.Fatalf("Test case %d: Expected the only healthy server, got %s", i, server.URL.Host)
+		}
+	}
+
+	pool[4].Healthy = false
+	if _, err := s.Next(newRequest(ip), pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
+
+func TestP2CLatency(t *testing.T) {
+	pool := newPool(2)
+	pool[0].UpdateStats(100 * time.Millisecond)
+	pool[1].UpdateStats(10 * time.Millisecond)
+	pool[1].ActiveConnections = 3
+	s := &P2C{UseLatency: true}
+
+	// 10ms with 3 in flight is still cheaper than 100ms with none
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if server != pool[1] {
+		t.Fatalf("Expected faster server %s, got %s", pool[1].URL.Host, server.URL.Host)
+	}
+}
+
+func TestP2CLatencyNewServer(t *testing.T) {
+	pool := newPool(3)
+	pool[0].UpdateStats(100 * time.Millisecond)
+	pool[1].UpdateStats(100 * time.Millisecond)
+	pool[0].ActiveConnections = 1
+	s := &P2C{UseLatency: true}
+
+	// The new server counts as average instead of free, so it doesn't win while it is busier
+	pool[2].ActiveConnections = 2
+	for i := 0; i < 20; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server == pool[2] {
+			t.Fatalf("Test case %d: Expected the new server to cost as much as the pool", i)
+		}
+	}
+}
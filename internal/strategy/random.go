@@ -0,0 +1,35 @@
+package strategy
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.Random, func(_ Options) Strategy {
+		return &Random{}
+	})
+}
+
+type Random struct{}
+
+func (rs *Random) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	healthyServers := make([]*server.Server, 0)
+	for _, s := range pool {
+		if isAvailable(req, s) {
+			healthyServers = append(healthyServers, s)
+		}
+	}
+
+	if len(healthyServers) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+
+	max := len(healthyServers)
+	idx := rand.Intn(max)
+
+	return healthyServers[idx], nil
+}
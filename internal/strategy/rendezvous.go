@@ -0,0 +1,53 @@
+package strategy
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.Rendezvous, func(opts Options) Strategy {
+		return &Rendezvous{HashKey: opts.HashKey}
+	})
+}
+
+// Rendezvous implements highest random weight hashing. Every server gets a score
+// for the request key and the server with the highest score wins. Removing a server
+// only moves the keys it owned, and weights are honoured without virtual nodes.
+type Rendezvous struct {
+	HashKey KeyFunc
+}
+
+func (rv *Rendezvous) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	key := getHashKey(req, rv.HashKey)
+
+	var best *server.Server
+	bestScore := math.Inf(-1)
+	for _, m := range getPoolMembers(pool) {
+		if !isAvailable(req, m.server) {
+			continue
+		}
+		score := getRendezvousScore(hash64(m.server.URL.String()+"#"+key), m.weight)
+		if score > bestScore {
+			bestScore = score
+			best = m.server
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoHealthyServers
+	}
+
+	return best, nil
+}
+
+// getRendezvousScore maps the hash to (0, 1) and scales it by the weight,
+// so the probability of winning is proportional to the weight of the server
+func getRendezvousScore(hash uint64, weight int) float64 {
+	u := (float64(hash>>11) + 0.5) / float64(1<<53)
+
+	return -float64(weight) / math.Log(u)
+}
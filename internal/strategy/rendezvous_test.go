@@ -0,0 +1,58 @@
+package strategy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func TestRendezvousMinimalDisruption(t *testing.T) {
+	pool := newPool(5)
+	s := &Rendezvous{}
+	clients := 10000
+
+	before := make([]*server.Server, clients)
+	for i := 0; i < clients; i++ {
+		before[i], _ = s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+	}
+
+	// Only the clients of the unhealthy server should move
+	pool[2].Healthy = false
+	for i := 0; i < clients; i++ {
+		after, _ := s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+		if before[i] != pool[2] && after != before[i] {
+			t.Fatalf("Expected client %d to stay on %s, got %s", i, before[i].URL.Host, after.URL.Host)
+		}
+		if after == pool[2] {
+			t.Fatalf("Expected unhealthy server to be skipped for client %d", i)
+		}
+	}
+}
+
+func TestRendezvousWeights(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Weight = 3
+	s := &Rendezvous{}
+
+	counts := map[*server.Server]int{}
+	for i := 0; i < 10000; i++ {
+		server, _ := s.Next(newRequest(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), pool)
+		counts[server]++
+	}
+
+	if counts[pool[0]] < 7000 || counts[pool[0]] > 8000 {
+		t.Fatalf("Expected about 7500 clients on the weighted server, got %d", counts[pool[0]])
+	}
+}
+
+func TestRendezvousNoHealthyServers(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Healthy = false
+	pool[1].Healthy = false
+	s := &Rendezvous{}
+
+	if _, err := s.Next(newRequest(ip), pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
@@ -0,0 +1,58 @@
+package strategy
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.RoundRobin, func(_ Options) Strategy {
+		return &RoundRobin{}
+	})
+}
+
+type RoundRobin struct {
+	Mut        sync.Mutex
+	NextServer int
+}
+
+func (rr *RoundRobin) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	rr.Mut.Lock()
+	defer rr.Mut.Unlock()
+
+	if len(pool) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+	if rr.NextServer >= len(pool) {
+		rr.NextServer = 0
+	}
+
+	server := pool[rr.NextServer]
+	if !isAvailable(req, server) {
+		for i := 0; i < len(pool)-1; i++ {
+			rr.incrementNextServer(len(pool))
+			server = pool[rr.NextServer]
+			if isAvailable(req, server) {
+				break
+			}
+		}
+
+		if !isAvailable(req, server) {
+			return nil, ErrNoHealthyServers
+		}
+	}
+
+	rr.incrementNextServer(len(pool))
+
+	return server, nil
+}
+
+func (rr *RoundRobin) incrementNextServer(poolSize int) {
+	rr.NextServer++
+	if rr.NextServer >= poolSize {
+		rr.NextServer = 0
+	}
+}
@@ -0,0 +1,153 @@
+package strategy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+type StickySessionOptions struct {
+	CookieName string
+	// TTL of the cookie, a zero TTL issues a session cookie
+	TTL      time.Duration
+	Secure   bool
+	HTTPOnly bool
+	SameSite http.SameSite
+	// Secret signs the cookie, so clients can't pick a server on their own
+	Secret []byte
+	// KeepDraining keeps clients on their server while it is draining
+	KeepDraining bool
+}
+
+// StickySession pins clients to a server with a signed cookie. The wrapped strategy only
+// decides the first pick, later requests go to the same server while it is healthy and
+// fail over to a new pick, with a fresh cookie, when it is not.
+type StickySession struct {
+	StickySessionOptions
+	Inner Strategy
+}
+
+func NewStickySession(inner Strategy, opts StickySessionOptions) *StickySession {
+	return &StickySession{
+		StickySessionOptions: opts,
+		Inner:                inner,
+	}
+}
+
+func (ss *StickySession) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	if id, ok := ss.getCookieServerID(req); ok {
+		for _, s := range pool {
+			if getServerID(s) == id && ss.isAvailable(req, s) {
+				return s, nil
+			}
+		}
+	}
+
+	return ss.Inner.Next(req, pool)
+}
+
+func (ss *StickySession) isAvailable(req *http.Request, s *server.Server) bool {
+	if ss.KeepDraining {
+		return s.IsAvailableForSession() && !IsExcluded(req, s)
+	}
+
+	return isAvailable(req, s)
+}
+
+func (ss *StickySession) OnRequestStart(s *server.Server) {
+	if hook, ok := ss.Inner.(RequestStartHook); ok {
+		hook.OnRequestStart(s)
+	}
+}
+
+func (ss *StickySession) OnRequestFinish(s *server.Server, elapsed time.Duration) {
+	if hook, ok := ss.Inner.(RequestFinishHook); ok {
+		hook.OnRequestFinish(s, elapsed)
+	}
+}
+
+// IsPinned reports whether the request has a valid cookie for the server
+func (ss *StickySession) IsPinned(req *http.Request, s *server.Server) bool {
+	id, ok := ss.getCookieServerID(req)
+
+	return ok && id == getServerID(s)
+}
+
+// OnResponseHeader sets the cookie, unless the client is already pinned to the server
+func (ss *StickySession) OnResponseHeader(req *http.Request, s *server.Server, header http.Header) {
+	if ss.IsPinned(req, s) {
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     ss.CookieName,
+		Value:    ss.sign(getServerID(s), time.Now()),
+		Path:     "/",
+		Secure:   ss.Secure,
+		HttpOnly: ss.HTTPOnly,
+		SameSite: ss.SameSite,
+	}
+	if ss.TTL > 0 {
+		cookie.MaxAge = int(ss.TTL.Seconds())
+	}
+	header.Add("Set-Cookie", cookie.String())
+}
+
+// sign returns the cookie value: the server ID, when it expires and a signature of both
+func (ss *StickySession) sign(id string, now time.Time) string {
+	expires := int64(0)
+	if ss.TTL > 0 {
+		expires = now.Add(ss.TTL).Unix()
+	}
+	payload := id + "." + strconv.FormatInt(expires, 10)
+
+	return payload + "." + ss.getSignature(payload)
+}
+
+func (ss *StickySession) getCookieServerID(req *http.Request) (string, bool) {
+	cookie, err := req.Cookie(ss.CookieName)
+	if err != nil {
+		return "", false
+	}
+
+	idx := strings.LastIndex(cookie.Value, ".")
+	if idx == -1 {
+		return "", false
+	}
+	payload, signature := cookie.Value[:idx], cookie.Value[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(ss.getSignature(payload))) {
+		return "", false
+	}
+
+	id, expiresValue, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(expiresValue, 10, 64)
+	if err != nil || (expires > 0 && time.Now().Unix() > expires) {
+		return "", false
+	}
+
+	return id, true
+}
+
+func (ss *StickySession) getSignature(payload string) string {
+	mac := hmac.New(sha256.New, ss.Secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// getServerID returns an opaque ID for the server, so the cookie doesn't leak backend URLs
+func getServerID(s *server.Server) string {
+	sum := sha256.Sum256([]byte(s.URL.String()))
+
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,143 @@
+package strategy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func newStickySession() *StickySession {
+	return NewStickySession(&RoundRobin{}, StickySessionOptions{
+		CookieName: "session",
+		TTL:        time.Hour,
+		Secret:     []byte("secret"),
+	})
+}
+
+// getStickyCookie runs the response hook and returns the cookie it set, if any
+func getStickyCookie(ss *StickySession, req *http.Request, s *server.Server) *http.Cookie {
+	header := http.Header{}
+	ss.OnResponseHeader(req, s, header)
+	cookies := (&http.Response{Header: header}).Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	return cookies[0]
+}
+
+func TestStickySession(t *testing.T) {
+	pool := newPool(3)
+	ss := newStickySession()
+
+	req := newRequest(ip)
+	first, _ := ss.Next(req, pool)
+	cookie := getStickyCookie(ss, req, first)
+	if cookie == nil {
+		t.Fatalf("Expected a cookie to be set on the first request")
+	}
+	if cookie.MaxAge != 3600 {
+		t.Fatalf("Expected cookie max age to be 3600, got %d", cookie.MaxAge)
+	}
+
+	for i := 0; i < 5; i++ {
+		req := newRequest(ip)
+		req.AddCookie(cookie)
+		server, err := ss.Next(req, pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != first {
+			t.Fatalf("Test case %d: Expected sticky server %s, got %s", i, first.URL.Host, server.URL.Host)
+		}
+		if getStickyCookie(ss, req, server) != nil {
+			t.Fatalf("Test case %d: Expected no new cookie for a pinned client", i)
+		}
+	}
+}
+
+func TestStickySessionFailover(t *testing.T) {
+	pool := newPool(3)
+	ss := newStickySession()
+
+	req := newRequest(ip)
+	first, _ := ss.Next(req, pool)
+	cookie := getStickyCookie(ss, req, first)
+	first.Healthy = false
+
+	req = newRequest(ip)
+	req.AddCookie(cookie)
+	second, err := ss.Next(req, pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if second == first {
+		t.Fatalf("Expected unhealthy sticky server to be skipped")
+	}
+	freshCookie := getStickyCookie(ss, req, second)
+	if freshCookie == nil || freshCookie.Value == cookie.Value {
+		t.Fatalf("Expected a fresh cookie for the new server")
+	}
+}
+
+func TestStickySessionDraining(t *testing.T) {
+	pool := newPool(3)
+	ss := newStickySession()
+
+	req := newRequest(ip)
+	first, _ := ss.Next(req, pool)
+	cookie := getStickyCookie(ss, req, first)
+	first.SetDraining(true)
+
+	req = newRequest(ip)
+	req.AddCookie(cookie)
+	second, _ := ss.Next(req, pool)
+	if second == first {
+		t.Fatalf("Expected draining sticky server to be skipped")
+	}
+
+	ss.KeepDraining = true
+	for i := 0; i < 3; i++ {
+		server, _ := ss.Next(req, pool)
+		if server != first {
+			t.Fatalf("Test case %d: Expected pinned client to stay on draining server %s, got %s", i, first.URL.Host, server.URL.Host)
+		}
+	}
+	// New clients never go to a draining server
+	for i := 0; i < 6; i++ {
+		server, _ := ss.Next(newRequest(ip), pool)
+		if server == first {
+			t.Fatalf("Test case %d: Expected new client to skip draining server", i)
+		}
+	}
+}
+
+func TestStickySessionInvalidCookie(t *testing.T) {
+	pool := newPool(3)
+	ss := newStickySession()
+
+	req := newRequest(ip)
+	first, _ := ss.Next(req, pool)
+	cookie := getStickyCookie(ss, req, first)
+
+	testCases := []struct {
+		name  string
+		value string
+	}{
+		{name: "raw url", value: first.URL.String()},
+		{name: "tampered id", value: getServerID(pool[1]) + cookie.Value[len(getServerID(first)):]},
+		{name: "other secret", value: (&StickySession{StickySessionOptions: StickySessionOptions{Secret: []byte("other")}}).sign(getServerID(pool[1]), time.Now())},
+		{name: "expired", value: ss.sign(getServerID(pool[1]), time.Now().Add(-2*time.Hour))},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: tc.value})
+		if _, ok := ss.getCookieServerID(req); ok {
+			t.Fatalf("%s: Expected cookie to be rejected", tc.name)
+		}
+	}
+}
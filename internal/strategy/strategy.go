@@ -0,0 +1,134 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+var ErrNoHealthyServers = errors.New("No healthy servers")
+
+// Strategy picks the server that should handle the next request out of the pool.
+// Implementations must be safe for concurrent use.
+type Strategy interface {
+	Next(req *http.Request, pool []*server.Server) (*server.Server, error)
+}
+
+// RequestStartHook can be implemented by strategies that need to know when a request is sent to a server
+type RequestStartHook interface {
+	OnRequestStart(s *server.Server)
+}
+
+// RequestFinishHook can be implemented by strategies that need to know when a server has answered a request
+type RequestFinishHook interface {
+	OnRequestFinish(s *server.Server, elapsed time.Duration)
+}
+
+// ResponseHeaderHook can be implemented by strategies that need to add headers to the response sent to the client
+type ResponseHeaderHook interface {
+	OnResponseHeader(req *http.Request, s *server.Server, header http.Header)
+}
+
+// AffinityStrategy can be implemented by strategies that pin clients to a server,
+// IsPinned reports whether the request carries a valid pin to s
+type AffinityStrategy interface {
+	IsPinned(req *http.Request, s *server.Server) bool
+}
+
+// WeightedStrategy can be implemented by strategies that need every server to have a weight of at least 1
+type WeightedStrategy interface {
+	RequiresWeights() bool
+}
+
+// Options holds the settings from the config that strategies may use
+type Options struct {
+	// VirtualNodes is the number of points each server gets on a hash ring, scaled by its weight
+	VirtualNodes int
+	// HashKey extracts the key that hash based strategies use, defaults to the client IP
+	HashKey KeyFunc
+	// LatencyHalfLife is how fast least-response-time forgets old response times
+	LatencyHalfLife time.Duration
+	// LatencyProbation is how long least-response-time treats new and recovered servers as average
+	LatencyProbation time.Duration
+	// StickySession wraps the strategy in sticky sessions when it is set
+	StickySession *StickySessionOptions
+}
+
+// Factory creates a new instance of a strategy, so every load balancer keeps its own state
+type Factory func(opts Options) Strategy
+
+var (
+	registryMut sync.RWMutex
+	registry    = map[constants.Strategy]Factory{}
+)
+
+// Register makes a strategy available by name, in the config and in New.
+// It panics if a strategy with the same name is already registered.
+func Register(name constants.Strategy, factory Factory) {
+	registryMut.Lock()
+	defer registryMut.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("strategy %s is already registered", name))
+	}
+	registry[name] = factory
+}
+
+// New creates the strategy registered under the given name
+func New(name constants.Strategy, opts Options) (Strategy, error) {
+	registryMut.RLock()
+	factory, ok := registry[name]
+	registryMut.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("strategy %s is not supported", name)
+	}
+
+	s := factory(opts)
+	if opts.StickySession != nil {
+		return NewStickySession(s, *opts.StickySession), nil
+	}
+
+	return s, nil
+}
+
+// Exists reports whether a strategy is registered under the given name
+func Exists(name constants.Strategy) bool {
+	registryMut.RLock()
+	defer registryMut.RUnlock()
+
+	_, ok := registry[name]
+
+	return ok
+}
+
+// RequiresWeights reports whether the strategy registered under the given name needs server weights
+func RequiresWeights(name constants.Strategy) bool {
+	s, err := New(name, Options{})
+	if err != nil {
+		return false
+	}
+	weighted, ok := s.(WeightedStrategy)
+
+	return ok && weighted.RequiresWeights()
+}
+
+// Names returns the names of all registered strategies, sorted
+func Names() []constants.Strategy {
+	registryMut.RLock()
+	defer registryMut.RUnlock()
+
+	names := make([]constants.Strategy, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,670 @@
+package strategy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+var ip = "127.0.0.1"
+
+func newRequest(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+
+	return req
+}
+
+func TestRoundRobinGetNextServer(t *testing.T) {
+	pool := []*server.Server{
+		{
+			URL:     &url.URL{Host: "localhost:8080"},
+			Healthy: true,
+		},
+		{
+			URL:     &url.URL{Host: "localhost:8081"},
+			Healthy: true,
+		},
+	}
+	s := &RoundRobin{}
+
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+
+	if server.URL.Host != "localhost:8080" {
+		t.Fatalf("Expected server to be localhost:8080, got %s", server.URL.Host)
+	}
+
+	server, err = s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+
+	if server.URL.Host != "localhost:8081" {
+		t.Fatalf("Expected server to be localhost:8081, got %s", server.URL.Host)
+	}
+
+	server, err = s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+
+	if server.URL.Host != "localhost:8080" {
+		t.Fatalf("Expected server to be localhost:8080, got %s", server.URL.Host)
+	}
+}
+
+func TestRoundRobinNextServerNoHealthyServers(t *testing.T) {
+	pool := []*server.Server{
+		{
+			URL:     &url.URL{Host: "localhost:8080"},
+			Healthy: false,
+		},
+		{
+			URL:     &url.URL{Host: "localhost:8081"},
+			Healthy: false,
+		},
+	}
+	s := &RoundRobin{}
+
+	_, err := s.Next(newRequest(ip), pool)
+	if err == nil {
+		t.Fatalf("Expected error getting next server")
+	}
+}
+
+func TestRoundRobinNextServerOneUnhealthyServer(t *testing.T) {
+	pool := []*server.Server{
+		{
+			URL:     &url.URL{Host: "localhost:8080"},
+			Healthy: false,
+		},
+		{
+			URL:     &url.URL{Host: "localhost:8081"},
+			Healthy: true,
+		},
+	}
+	s := &RoundRobin{}
+
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if server.URL.Host != "localhost:8081" {
+		t.Fatalf("Expected server to be localhost:8081, got %s", server.URL.Host)
+	}
+}
+
+func TestWeightedRoundRobinNextserverNoHealthyServers(t *testing.T) {
+	pool := []*server.Server{
+		{
+			URL:     &url.URL{Host: "localhost:8080"},
+			Healthy: false,
+			Weight:  0,
+		},
+		{
+			URL:     &url.URL{Host: "localhost:8081"},
+			Healthy: false,
+			Weight:  0,
+		},
+	}
+	s := &WeightedRoundRobin{}
+
+	_, err := s.Next(newRequest(ip), pool)
+	if err == nil {
+		t.Fatalf("Expected error getting next server")
+	}
+}
+
+func TestWeightedRoundRobinNextServer(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 5),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 2),
+	}
+	s := &WeightedRoundRobin{}
+
+	testCases := []struct {
+		expectedHost   string
+		expectedWeight int
+	}{
+		// cycle one
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8081", expectedWeight: -4},
+		{expectedHost: "localhost:8082", expectedWeight: -4},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8082", expectedWeight: -4},
+		{expectedHost: "localhost:8081", expectedWeight: -3},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+
+		// cycle two
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8081", expectedWeight: -4},
+		{expectedHost: "localhost:8082", expectedWeight: -4},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8080", expectedWeight: -5},
+		{expectedHost: "localhost:8082", expectedWeight: -4},
+		{expectedHost: "localhost:8081", expectedWeight: -3},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		if server.CurrentWeight != tc.expectedWeight {
+			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.CurrentWeight)
+		}
+	}
+}
+
+func TestWeightedRoundRobinNextServerOneUnhealthyServer(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 5),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
+		{
+			URL:     &url.URL{Host: "localhost:8082"},
+			Healthy: false,
+			Weight:  2,
+		},
+	}
+	s := &WeightedRoundRobin{}
+
+	testCases := []struct {
+		expectedHost   string
+		expectedWeight int
+	}{
+		// cycle one
+		{expectedHost: "localhost:8080", expectedWeight: -3},
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8080", expectedWeight: -1},
+		{expectedHost: "localhost:8080", expectedWeight: -4},
+		{expectedHost: "localhost:8081", expectedWeight: -1},
+		{expectedHost: "localhost:8080", expectedWeight: -2},
+		{expectedHost: "localhost:8081", expectedWeight: -3},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+
+		// cycle two
+		{expectedHost: "localhost:8080", expectedWeight: -3},
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8080", expectedWeight: -1},
+		{expectedHost: "localhost:8080", expectedWeight: -4},
+		{expectedHost: "localhost:8081", expectedWeight: -1},
+		{expectedHost: "localhost:8080", expectedWeight: -2},
+		{expectedHost: "localhost:8081", expectedWeight: -3},
+		{expectedHost: "localhost:8080", expectedWeight: 0},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		if server.CurrentWeight != tc.expectedWeight {
+			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.CurrentWeight)
+		}
+	}
+}
+
+func TestWeightedRoundRobinNextServerFirstUnhealthyServer(t *testing.T) {
+	pool := []*server.Server{
+		{
+			URL:     &url.URL{Host: "localhost:8080"},
+			Healthy: false,
+			Weight:  5,
+		},
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 3),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 2),
+	}
+	s := &WeightedRoundRobin{}
+
+	testCases := []struct {
+		expectedHost   string
+		expectedWeight int
+	}{
+		// cycle one
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8082", expectedWeight: -1},
+		{expectedHost: "localhost:8081", expectedWeight: -1},
+		{expectedHost: "localhost:8082", expectedWeight: -2},
+		{expectedHost: "localhost:8081", expectedWeight: 0},
+
+		// cycle two
+		{expectedHost: "localhost:8081", expectedWeight: -2},
+		{expectedHost: "localhost:8082", expectedWeight: -1},
+		{expectedHost: "localhost:8081", expectedWeight: -1},
+		{expectedHost: "localhost:8082", expectedWeight: -2},
+		{expectedHost: "localhost:8081", expectedWeight: 0},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		if server.CurrentWeight != tc.expectedWeight {
+			t.Fatalf("Test case %d: Expected weight to be %d, got %d", i, tc.expectedWeight, server.CurrentWeight)
+		}
+	}
+}
+
+func TestWeightedRoundRobinNextServerZeroWeight(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 2),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &WeightedRoundRobin{}
+
+	// Servers without a weight count as weight 1
+	expectedHosts := []string{"localhost:8081", "localhost:8080", "localhost:8082", "localhost:8081"}
+	for i, expectedHost := range expectedHosts {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, expectedHost, server.URL.Host)
+		}
+	}
+}
+
+func TestIpHashingNextServer(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &IPHashing{}
+
+	testCases := []struct {
+		ip           string
+		expectedHost string
+	}{
+		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
+		{ip: "127.0.0.2", expectedHost: "localhost:8080"},
+		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
+		{ip: "127.0.0.2", expectedHost: "localhost:8080"},
+		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(tc.ip), pool)
+
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+	}
+}
+
+func TestIpHashingNextServerUnhealthyServer(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &IPHashing{}
+	pool[0].Healthy = false
+
+	testCases := []struct {
+		ip           string
+		expectedHost string
+	}{
+		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
+		{ip: "127.0.0.2", expectedHost: "localhost:8081"}, // 8080 is unhealthy, so it goes to next healthy server
+		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+		{ip: "127.0.0.1", expectedHost: "localhost:8082"},
+		{ip: "127.0.0.2", expectedHost: "localhost:8081"}, // 8080 is unhealthy, so it goes to next healthy server
+		{ip: "127.0.0.3", expectedHost: "localhost:8081"},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(tc.ip), pool)
+
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &LeastConnections{}
+	pool[0].ActiveConnections = 2
+	pool[1].ActiveConnections = 5
+	pool[2].ActiveConnections = 0
+
+	testCases := []struct {
+		expectedHost string
+	}{
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8080"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8080"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8080"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8080"},
+		{expectedHost: "localhost:8081"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8080"},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(ip), pool)
+
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		server.ActiveConnections++
+	}
+}
+func TestLeastConnectionsUnhealthyServer(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &LeastConnections{}
+	pool[0].ActiveConnections = 2
+	pool[1].ActiveConnections = 5
+	pool[2].ActiveConnections = 0
+	pool[0].Healthy = false
+
+	testCases := []struct {
+		expectedHost string
+	}{
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8082"},
+		{expectedHost: "localhost:8081"},
+		{expectedHost: "localhost:8082"},
+	}
+
+	for i, tc := range testCases {
+		server, err := s.Next(newRequest(ip), pool)
+
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		server.ActiveConnections++
+	}
+}
+
+func TestGetNextServerLeastResponseTimeNoHealthyServers(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8082"}, 0),
+	}
+	s := &LeastResponseTime{}
+	pool[0].Healthy = false
+	pool[1].Healthy = false
+	pool[2].Healthy = false
+
+	_, err := s.Next(newRequest(ip), pool)
+	if err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
+
+func TestGetNextServerLeastResponseTime(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+	}
+	now := time.Now()
+	s := &LeastResponseTime{HalfLife: time.Second, now: func() time.Time { return now }}
+
+	testCases := []struct {
+		expectedHost string
+		serverIdx    int
+		duration     time.Duration
+		wait         time.Duration
+	}{
+		// Servers without response times are tried first
+		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond},
+		{expectedHost: "localhost:8081", serverIdx: 1, duration: 50 * time.Millisecond},
+		// A slow response counts in full straight away
+		{expectedHost: "localhost:8081", serverIdx: 1, duration: 600 * time.Millisecond},
+		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond},
+		// The slow spell halves every second
+		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond, wait: time.Second},
+		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond, wait: time.Second},
+		{expectedHost: "localhost:8080", serverIdx: 0, duration: 100 * time.Millisecond, wait: time.Second},
+		{expectedHost: "localhost:8081", serverIdx: 1, duration: 50 * time.Millisecond, wait: time.Second},
+	}
+
+	for i, tc := range testCases {
+		now = now.Add(tc.wait)
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server.URL.Host != tc.expectedHost {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, tc.expectedHost, server.URL.Host)
+		}
+		s.OnRequestFinish(pool[tc.serverIdx], tc.duration)
+	}
+}
+
+func TestGetNextServerLeastResponseTimeActiveConnections(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+	}
+	s := &LeastResponseTime{}
+	s.OnRequestFinish(pool[0], 10*time.Millisecond)
+	s.OnRequestFinish(pool[1], 30*time.Millisecond)
+
+	// 10ms with 3 requests in flight costs more than 30ms with none
+	pool[0].ActiveConnections = 3
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if server != pool[1] {
+		t.Fatalf("Expected server to be %s, got %s", pool[1].URL.Host, server.URL.Host)
+	}
+}
+
+func TestGetNextServerLeastResponseTimeProbation(t *testing.T) {
+	pool := []*server.Server{
+		server.NewServer(&url.URL{Host: "localhost:8080"}, 0),
+		server.NewServer(&url.URL{Host: "localhost:8081"}, 0),
+	}
+	now := time.Now().Add(time.Minute)
+	s := &LeastResponseTime{Probation: 10 * time.Second, now: func() time.Time { return now }}
+	s.OnRequestFinish(pool[0], 100*time.Millisecond)
+	s.OnRequestFinish(pool[1], 10*time.Millisecond)
+
+	// The recovered server is treated as average, so it only gets its share of the traffic
+	pool[1].SetHealthy(false)
+	pool[1].SetHealthy(true)
+	pool[1].HealthySince = now
+	pool[0].ActiveConnections = 1
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if server != pool[1] {
+		t.Fatalf("Expected server to be %s, got %s", pool[1].URL.Host, server.URL.Host)
+	}
+	pool[1].ActiveConnections = 1
+	if server, _ = s.Next(newRequest(ip), pool); server != pool[0] {
+		t.Fatalf("Expected server on probation to cost as much as the pool, got %s", server.URL.Host)
+	}
+
+	// Once probation is over its own response times count
+	now = now.Add(10 * time.Second)
+	if server, _ = s.Next(newRequest(ip), pool); server != pool[1] {
+		t.Fatalf("Expected server to be %s after probation, got %s", pool[1].URL.Host, server.URL.Host)
+	}
+}
+
+func TestGetNextServerLeastResponseTimeForgetsRemovedServers(t *testing.T) {
+	pool := newPool(3)
+	s := &LeastResponseTime{}
+	for _, server := range pool {
+		s.OnRequestFinish(server, 10*time.Millisecond)
+	}
+
+	// A reload removed the last server
+	if _, err := s.Next(newRequest(ip), pool[:2]); err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if _, ok := s.latencies[pool[2]]; ok || len(s.latencies) != 2 {
+		t.Fatalf("Expected the removed server to be forgotten, got %d response times", len(s.latencies))
+	}
+}
+
+func TestStrategiesSkipExcludedServers(t *testing.T) {
+	for _, name := range Names() {
+		pool := newPool(3)
+		s, err := New(name, Options{})
+		if err != nil {
+			t.Fatalf("Error creating strategy %s: %s", name, err)
+		}
+
+		for i := 0; i < 20; i++ {
+			req := WithExcluded(newRequest(fmt.Sprintf("127.0.0.%d", i)), pool[0], pool[2])
+			server, err := s.Next(req, pool)
+			if err != nil {
+				t.Fatalf("Strategy %s: Error getting next server: %s", name, err)
+			}
+			if server != pool[1] {
+				t.Fatalf("Strategy %s: Expected the only server that isn't excluded, got %s", name, server.URL.Host)
+			}
+		}
+
+		req := WithExcluded(WithExcluded(newRequest(ip), pool[0], pool[2]), pool[1])
+		if _, err := s.Next(req, pool); err == nil {
+			t.Fatalf("Strategy %s: Expected error when every server is excluded", name)
+		}
+	}
+}
+
+type firstServer struct{}
+
+func (f *firstServer) Next(_ *http.Request, pool []*server.Server) (*server.Server, error) {
+	if len(pool) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+
+	return pool[0], nil
+}
+
+func TestRegister(t *testing.T) {
+	name := constants.Strategy("first-server")
+	Register(name, func(_ Options) Strategy {
+		return &firstServer{}
+	})
+
+	if !Exists(name) {
+		t.Fatalf("Expected %s to be registered", name)
+	}
+	if !slices.Contains(Names(), name) {
+		t.Fatalf("Expected %s to be listed in %v", name, Names())
+	}
+	s, err := New(name, Options{})
+	if err != nil {
+		t.Fatalf("Error creating strategy: %s", err.Error())
+	}
+	if _, ok := s.(*firstServer); !ok {
+		t.Fatalf("Expected registered strategy, got %T", s)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected registering %s twice to panic", name)
+		}
+	}()
+	Register(name, func(_ Options) Strategy {
+		return &firstServer{}
+	})
+}
+
+func TestNewUnknownStrategy(t *testing.T) {
+	if Exists("invalid-strategy") {
+		t.Fatalf("Expected invalid-strategy not to be registered")
+	}
+	if _, err := New("invalid-strategy", Options{}); err == nil {
+		t.Fatalf("Expected error creating unknown strategy")
+	}
+}
+
+func TestHashingStrategiesShareHashKey(t *testing.T) {
+	pool := newPool(5)
+	hashKey := func(req *http.Request) string {
+		return req.Header.Get("X-User")
+	}
+	strategies := []Strategy{
+		&IPHashing{HashKey: hashKey},
+		&ConsistentHashing{HashKey: hashKey},
+		&Maglev{HashKey: hashKey},
+		&Rendezvous{HashKey: hashKey},
+	}
+
+	for _, s := range strategies {
+		first := newRequest("10.0.0.1:1234")
+		first.Header.Set("X-User", "alice")
+		second := newRequest("10.0.0.2:4321")
+		second.Header.Set("X-User", "alice")
+
+		expected, err := s.Next(first, pool)
+		if err != nil {
+			t.Fatalf("%T: Error getting next server: %s", s, err.Error())
+		}
+		server, err := s.Next(second, pool)
+		if err != nil {
+			t.Fatalf("%T: Error getting next server: %s", s, err.Error())
+		}
+		if server != expected {
+			t.Fatalf("%T: Expected requests with the same key to go to %s, got %s", s, expected.URL.Host, server.URL.Host)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package strategy
+
+import (
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.WeightedLeastConnections, func(_ Options) Strategy {
+		return &WeightedLeastConnections{}
+	})
+}
+
+// WeightedLeastConnections picks the healthy server with the fewest active connections per unit
+// of weight, ties go to the server with the bigger weight
+type WeightedLeastConnections struct{}
+
+func (wlc *WeightedLeastConnections) RequiresWeights() bool {
+	return true
+}
+
+func (wlc *WeightedLeastConnections) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	idx := -1
+	minActiveConnections, minWeight := 0, 1
+	for i := 0; i < len(pool); i++ {
+		pool[i].Mut.Lock()
+		activeConnections, weight := pool[i].ActiveConnections, max(pool[i].Weight, 1)
+		pool[i].Mut.Unlock()
+		healthy := isAvailable(req, pool[i])
+
+		if !healthy {
+			continue
+		}
+		// Compare activeConnections/weight without dividing
+		load, minLoad := activeConnections*minWeight, minActiveConnections*weight
+		if idx == -1 || load < minLoad || (load == minLoad && weight > minWeight) {
+			minActiveConnections, minWeight = activeConnections, weight
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return nil, ErrNoHealthyServers
+	}
+
+	return pool[idx], nil
+}
@@ -0,0 +1,44 @@
+package strategy
+
+import "testing"
+
+func TestWeightedLeastConnections(t *testing.T) {
+	pool := newPool(2)
+	pool[0].Weight = 1
+	pool[1].Weight = 3
+	s := &WeightedLeastConnections{}
+
+	// The server with weight 3 takes 3 connections for every one on the other server
+	expectedHosts := []int{1, 0, 1, 1, 1, 0, 1, 1, 1, 0}
+	for i, expected := range expectedHosts {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != pool[expected] {
+			t.Fatalf("Test case %d: Expected server to be %s, got %s", i, pool[expected].URL.Host, server.URL.Host)
+		}
+		server.ActiveConnections++
+	}
+}
+
+func TestWeightedLeastConnectionsUnhealthyServers(t *testing.T) {
+	pool := newPool(2)
+	pool[1].Weight = 3
+	pool[1].Healthy = false
+	pool[0].ActiveConnections = 10
+	s := &WeightedLeastConnections{}
+
+	server, err := s.Next(newRequest(ip), pool)
+	if err != nil {
+		t.Fatalf("Error getting next server: %s", err.Error())
+	}
+	if server != pool[0] {
+		t.Fatalf("Expected the only healthy server, got %s", server.URL.Host)
+	}
+
+	pool[0].Healthy = false
+	if _, err := s.Next(newRequest(ip), pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
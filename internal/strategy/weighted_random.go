@@ -0,0 +1,53 @@
+package strategy
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.WeightedRandom, func(_ Options) Strategy {
+		return &WeightedRandom{}
+	})
+}
+
+// WeightedRandom picks a healthy server with a probability proportional to its weight
+type WeightedRandom struct{}
+
+func (wr *WeightedRandom) RequiresWeights() bool {
+	return true
+}
+
+func (wr *WeightedRandom) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	healthyServers := make([]*server.Server, 0)
+	weights := make([]int, 0)
+	total := 0
+	for _, s := range pool {
+		s.Mut.Lock()
+		weight := max(s.Weight, 1)
+		s.Mut.Unlock()
+
+		if isAvailable(req, s) {
+			healthyServers = append(healthyServers, s)
+			weights = append(weights, weight)
+			total += weight
+		}
+	}
+
+	if len(healthyServers) == 0 {
+		return nil, ErrNoHealthyServers
+	}
+
+	n := rand.Intn(total)
+	for i, weight := range weights {
+		if n < weight {
+			return healthyServers[i], nil
+		}
+		n -= weight
+	}
+
+	return healthyServers[len(healthyServers)-1], nil
+}
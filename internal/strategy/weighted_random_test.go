@@ -0,0 +1,50 @@
+package strategy
+
+import "testing"
+
+func TestWeightedRandom(t *testing.T) {
+	pool := newPool(3)
+	pool[0].Weight = 1
+	pool[1].Weight = 4
+	pool[2].Weight = 5
+	s := &WeightedRandom{}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		counts[server.URL.Host]++
+	}
+
+	for _, server := range pool {
+		expected := 1000 * server.Weight
+		if counts[server.URL.Host] < expected*8/10 || counts[server.URL.Host] > expected*12/10 {
+			t.Fatalf("Expected about %d requests on %s, got %d", expected, server.URL.Host, counts[server.URL.Host])
+		}
+	}
+}
+
+func TestWeightedRandomUnhealthyServers(t *testing.T) {
+	pool := newPool(3)
+	pool[0].Weight = 10
+	pool[0].Healthy = false
+	pool[2].Healthy = false
+	s := &WeightedRandom{}
+
+	for i := 0; i < 10; i++ {
+		server, err := s.Next(newRequest(ip), pool)
+		if err != nil {
+			t.Fatalf("Error getting next server: %s", err.Error())
+		}
+		if server != pool[1] {
+			t.Fatalf("Test case %d: Expected the only healthy server, got %s", i, server.URL.Host)
+		}
+	}
+
+	pool[1].Healthy = false
+	if _, err := s.Next(newRequest(ip), pool); err == nil {
+		t.Fatalf("Expected error when no healthy servers are available")
+	}
+}
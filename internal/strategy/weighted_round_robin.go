@@ -0,0 +1,60 @@
+package strategy
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+)
+
+func init() {
+	Register(constants.WeightedRoundRobin, func(_ Options) Strategy {
+		return &WeightedRoundRobin{}
+	})
+}
+
+// WeightedRoundRobin implements the smooth weighted round robin from nginx. On every pick each
+// healthy server's current weight grows by its effective weight, the server with the highest
+// current weight wins and gives back the total, which interleaves servers evenly, e.g. weights
+// 5, 3 and 2 give A,B,C,A,A,B,A,C,B,A. Unhealthy servers are left out of the round until they recover.
+type WeightedRoundRobin struct {
+	Mut sync.Mutex
+}
+
+func (wrr *WeightedRoundRobin) RequiresWeights() bool {
+	return true
+}
+
+func (wrr *WeightedRoundRobin) Next(req *http.Request, pool []*server.Server) (*server.Server, error) {
+	wrr.Mut.Lock()
+	defer wrr.Mut.Unlock()
+
+	var best *server.Server
+	bestWeight := 0
+	total := 0
+	for _, s := range pool {
+		if !isAvailable(req, s) {
+			continue
+		}
+		s.Mut.Lock()
+		effectiveWeight := max(s.Weight, 1)
+		s.CurrentWeight += effectiveWeight
+		total += effectiveWeight
+		if best == nil || s.CurrentWeight > bestWeight {
+			best = s
+			bestWeight = s.CurrentWeight
+		}
+		s.Mut.Unlock()
+	}
+
+	if best == nil {
+		return nil, ErrNoHealthyServers
+	}
+
+	best.Mut.Lock()
+	best.CurrentWeight -= total
+	best.Mut.Unlock()
+
+	return best, nil
+}
@@ -0,0 +1,153 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// envListeners names the listening sockets a process inherited, in the order of their file descriptors
+	envListeners = "TLB_LISTENERS"
+	// envParentPID is the process that handed over its sockets and waits to be told to exit
+	envParentPID = "TLB_PARENT_PID"
+)
+
+// File descriptor of the first inherited socket, after stdin, stdout and stderr
+var firstInheritedFD = 3
+
+// Listeners keeps the listening sockets of the load balancer, so they can be handed to a new binary
+// on an upgrade. The new process serves on the same sockets, so no connection is refused meanwhile.
+type Listeners struct {
+	mut       sync.Mutex
+	names     []string
+	listeners []net.Listener
+	inherited map[string]*os.File
+	parentPID int
+}
+
+// New picks up the sockets handed over by the parent process, when this process was started by an upgrade
+func New() (*Listeners, error) {
+	l := &Listeners{inherited: map[string]*os.File{}}
+	names := os.Getenv(envListeners)
+	if names == "" {
+		return l, nil
+	}
+
+	for i, name := range strings.Split(names, ",") {
+		l.inherited[name] = os.NewFile(uintptr(firstInheritedFD+i), name)
+	}
+	if pid := os.Getenv(envParentPID); pid != "" {
+		parentPID, err := strconv.Atoi(pid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parent pid %q: %w", pid, err)
+		}
+		l.parentPID = parentPID
+	}
+	// Processes started by this one must not think they inherited sockets too
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envParentPID)
+
+	return l, nil
+}
+
+// Listen returns the inherited socket with the given name, or listens on address when there is none
+func (l *Listeners) Listen(name string, address string) (net.Listener, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	var listener net.Listener
+	var err error
+	if f, ok := l.inherited[name]; ok {
+		delete(l.inherited, name)
+		listener, err = net.FileListener(f)
+		f.Close()
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.names = append(l.names, name)
+	l.listeners = append(l.listeners, listener)
+
+	return listener, nil
+}
+
+// Close stops accepting connections, the sockets stay open in a process they were handed to
+func (l *Listeners) Close() error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	var errs []error
+	for _, listener := range l.listeners {
+		errs = append(errs, listener.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsUpgrade reports whether this process was started by an upgrade
+func (l *Listeners) IsUpgrade() bool {
+	return l.parentPID != 0
+}
+
+// NotifyParent tells the process that started this one to shut down, once this one is serving
+func (l *Listeners) NotifyParent() error {
+	if l.parentPID == 0 {
+		return nil
+	}
+
+	return syscall.Kill(l.parentPID, syscall.SIGTERM)
+}
+
+// Upgrade starts the binary again with the same arguments and hands it the listening sockets.
+// The returned command is running, it signals this process once it serves.
+func (l *Listeners) Upgrade() (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	files := make([]*os.File, 0, len(l.listeners))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, listener := range l.listeners {
+		fileListener, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("listener %s can't be handed over", listener.Addr())
+		}
+		f, err := fileListener.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(l.names, ","),
+		envParentPID+"="+strconv.Itoa(os.Getpid()),
+	)
+	cmd.ExtraFiles = files
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,86 @@
+package upgrade
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	l, err := New()
+	if err != nil {
+		t.Fatalf("Error creating listeners: %s", err.Error())
+	}
+	if l.IsUpgrade() {
+		t.Fatalf("Expected a process without inherited sockets not to be an upgrade")
+	}
+
+	listener, err := l.Listen("lb", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer listener.Close()
+	if len(l.names) != 1 || l.names[0] != "lb" {
+		t.Fatalf("Expected the listener to be kept for upgrades, got %v", l.names)
+	}
+}
+
+func TestListenInherited(t *testing.T) {
+	parent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer parent.Close()
+	f, err := parent.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("Error getting listener file: %s", err.Error())
+	}
+	// The test process stands in for the new one, its inherited socket is a duplicate that New takes over
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatalf("Error duplicating listener: %s", err.Error())
+	}
+
+	defer func(fd int) {
+		firstInheritedFD = fd
+	}(firstInheritedFD)
+	firstInheritedFD = fd
+	t.Setenv(envListeners, "lb")
+	t.Setenv(envParentPID, strconv.Itoa(os.Getppid()))
+
+	l, err := New()
+	if err != nil {
+		t.Fatalf("Error creating listeners: %s", err.Error())
+	}
+	if !l.IsUpgrade() {
+		t.Fatalf("Expected a process with inherited sockets to be an upgrade")
+	}
+	if os.Getenv(envListeners) != "" {
+		t.Fatalf("Expected the marker to be removed from the environment")
+	}
+
+	listener, err := l.Listen("lb", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err.Error())
+	}
+	defer listener.Close()
+	if listener.Addr().String() != parent.Addr().String() {
+		t.Fatalf("Expected the inherited socket on %s, got %s", parent.Addr(), listener.Addr())
+	}
+
+	// Connections to the shared socket are accepted by the new listener
+	go func() {
+		conn, err := net.Dial("tcp", parent.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Error accepting: %s", err.Error())
+	}
+	conn.Close()
+}
@@ -0,0 +1,59 @@
+// Package strategy is the public API for adding load balancing strategies. Strategies registered
+// here are picked by name in the config like the built-in ones, see tinylb.Main for running them.
+package strategy
+
+import (
+	"net/http"
+
+	"github.com/tiny-loadbalancer/internal/constants"
+	"github.com/tiny-loadbalancer/internal/server"
+	istrategy "github.com/tiny-loadbalancer/internal/strategy"
+)
+
+// Server is a server of the pool
+type Server = server.Server
+
+// Name is the name a strategy is registered under and picked by in the config
+type Name = constants.Strategy
+
+// Strategy picks the server that should handle the next request out of the pool.
+// Implementations must be safe for concurrent use.
+type Strategy = istrategy.Strategy
+
+// RequestStartHook can be implemented by strategies that need to know when a request is sent to a server
+type RequestStartHook = istrategy.RequestStartHook
+
+// RequestFinishHook can be implemented by strategies that need to know when a server has answered a request
+type RequestFinishHook = istrategy.RequestFinishHook
+
+// ResponseHeaderHook can be implemented by strategies that need to add headers to the response sent to the client
+type ResponseHeaderHook = istrategy.ResponseHeaderHook
+
+// AffinityStrategy can be implemented by strategies that pin clients to a server
+type AffinityStrategy = istrategy.AffinityStrategy
+
+// WeightedStrategy can be implemented by strategies that need every server to have a weight of at least 1
+type WeightedStrategy = istrategy.WeightedStrategy
+
+// Options holds the settings from the config that strategies may use
+type Options = istrategy.Options
+
+// Factory creates a new instance of a strategy, so every load balancer keeps its own state
+type Factory = istrategy.Factory
+
+// KeyFunc extracts the key that hash based strategies use from a request
+type KeyFunc = istrategy.KeyFunc
+
+var ErrNoHealthyServers = istrategy.ErrNoHealthyServers
+
+// Register makes a strategy available by name in the config.
+// It panics if a strategy with the same name is already registered.
+func Register(name Name, factory Factory) {
+	istrategy.Register(name, factory)
+}
+
+// IsAvailable reports whether the server can take the request: it is healthy, not ejected, not
+// draining and the request didn't fail on it already
+func IsAvailable(req *http.Request, s *Server) bool {
+	return s.IsAvailable() && !istrategy.IsExcluded(req, s)
+}
@@ -0,0 +1,54 @@
+package strategy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/reload"
+	"github.com/tiny-loadbalancer/strategy"
+)
+
+// lastAvailable is written against the public API only, like a strategy of another module
+type lastAvailable struct{}
+
+func (l *lastAvailable) Next(req *http.Request, pool []*strategy.Server) (*strategy.Server, error) {
+	for i := len(pool) - 1; i >= 0; i-- {
+		if strategy.IsAvailable(req, pool[i]) {
+			return pool[i], nil
+		}
+	}
+
+	return nil, strategy.ErrNoHealthyServers
+}
+
+func init() {
+	strategy.Register("last-available", func(opts strategy.Options) strategy.Strategy {
+		return &lastAvailable{}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	c := &config.Config{Port: 8080, Strategy: "last-available", HealthCheckInterval: "5s"}
+	for _, name := range []string{"first", "second"} {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer backend.Close()
+		c.Servers = append(c.Servers, config.Server{Url: backend.URL, Weight: 1})
+	}
+	if err := c.ValidateConfig(c); err != nil {
+		t.Fatalf("Expected the registered strategy to be valid in the config, got %s", err.Error())
+	}
+
+	tlb, err := reload.NewLoadBalancer(c)
+	if err != nil {
+		t.Fatalf("Error building load balancer: %s", err.Error())
+	}
+	rec := httptest.NewRecorder()
+	tlb.GetRequestHandler()(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "second" {
+		t.Fatalf("Expected the registered strategy to pick the server, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,221 @@
+// Package tinylb runs the load balancer. Programs that link in their own strategies, registered with
+// the strategy package, call Main from their main function instead of forking the binary.
+package tinylb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tiny-loadbalancer/events"
+	"github.com/tiny-loadbalancer/internal/admin"
+	"github.com/tiny-loadbalancer/internal/config"
+	"github.com/tiny-loadbalancer/internal/constants"
+	ievents "github.com/tiny-loadbalancer/internal/events"
+	"github.com/tiny-loadbalancer/internal/reload"
+	"github.com/tiny-loadbalancer/internal/serve"
+	"github.com/tiny-loadbalancer/internal/upgrade"
+)
+
+// Options customize the load balancer started by Main
+type Options struct {
+	// Events receives the events of the load balancer and its health checks, next to the logs and
+	// the webhooks of the config. A bus of its own is used when it is nil.
+	Events *events.Bus
+}
+
+// Main runs the load balancer with the config file in args until a signal stops it, like the
+// tiny-loadbalancer binary does. Errors are logged and exit the process.
+func Main(args []string, opts Options) {
+	logFile, err := initLogger()
+	if err != nil {
+		log.Fatalf("Failed to init log file %s", err)
+	}
+	defer func() {
+		logFile.Sync()
+		logFile.Close()
+	}()
+	logger := slog.Default()
+
+	if len(args) == 0 {
+		logger.Error("Please provide a config file", "args", strings.Join(args, ", "))
+		os.Exit(1)
+	}
+	configPath := args[0]
+	c, err := reload.ReadConfig(configPath)
+	if err != nil {
+		logger.Error("Error reading config file", "error", err)
+		os.Exit(1)
+	}
+
+	tlb, err := reload.NewLoadBalancer(c)
+	if err != nil {
+		logger.Error("Invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	bus := opts.Events
+	if bus == nil {
+		bus = events.NewBus()
+	}
+	bus.Subscribe(&ievents.LogSubscriber{Logger: logger})
+	webhooks, err := reload.NewWebhooks(c)
+	if err != nil {
+		logger.Error("Invalid webhooks", "error", err)
+		os.Exit(1)
+	}
+	for _, webhook := range webhooks {
+		bus.Subscribe(webhook)
+		defer webhook.Close()
+	}
+	tlb.Events = bus
+
+	reloader := reload.New(configPath, c, tlb, logger, bus)
+	if err := reloader.Start(); err != nil {
+		logger.Error("Invalid health check", "error", err)
+		os.Exit(1)
+	}
+	defer reloader.Stop()
+	go reloader.WatchSignals()
+	var watchInterval time.Duration
+	if err := config.ParseDurations(config.Duration(c.ConfigWatchInterval, &watchInterval)); err != nil {
+		logger.Error("Invalid config watch interval", "error", err)
+		os.Exit(1)
+	}
+	if watchInterval > 0 {
+		go reloader.WatchFile(watchInterval)
+	}
+
+	listeners, err := upgrade.New()
+	if err != nil {
+		logger.Error("Invalid inherited sockets", "error", err)
+		os.Exit(1)
+	}
+
+	servers := serve.New(listeners)
+	serverErr := make(chan error, 2)
+	if c.Admin.Port > 0 {
+		adminAPI := &admin.API{
+			LoadBalancer: tlb,
+			Store:        reloader,
+			Token:        c.Admin.Token,
+			Logger:       logger,
+		}
+		adminAddress := net.JoinHostPort(cmp.Or(c.Admin.Host, constants.DefaultAdminHost), strconv.Itoa(c.Admin.Port))
+		adminListener, err := listeners.Listen("admin", adminAddress)
+		if err != nil {
+			logger.Error("Error starting admin API", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Starting admin API", "address", adminAddress)
+		servers.Serve(&http.Server{Handler: adminAPI.Handler()}, adminListener, serverErr)
+	}
+
+	ready := &serve.Readiness{}
+	mux := http.NewServeMux()
+	if c.Shutdown.ReadinessPath != "" {
+		mux.Handle(c.Shutdown.ReadinessPath, ready)
+	}
+	mux.HandleFunc("/", tlb.GetRequestHandler())
+	lbListener, err := listeners.Listen("lb", fmt.Sprintf(":%d", tlb.Port))
+	if err != nil {
+		logger.Error("Error starting loadbalancer", "error", err)
+		os.Exit(1)
+	}
+	log.Println("Starting server on port", tlb.Port)
+	servers.Serve(&http.Server{Handler: mux}, lbListener, serverErr)
+
+	// Once this process serves, the pid file points to it and the process it replaces can stop
+	if c.PidFile != "" {
+		if err := writePidFile(c.PidFile); err != nil {
+			logger.Error("Error writing pid file", "error", err)
+			os.Exit(1)
+		}
+		defer removePidFile(c.PidFile)
+	}
+	if listeners.IsUpgrade() {
+		logger.Info("Took over the sockets, stopping the old process")
+		if err := listeners.NotifyParent(); err != nil {
+			logger.Warn("Error stopping the old process", "error", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	upgradeSignals := make(chan os.Signal, 1)
+	signal.Notify(upgradeSignals, syscall.SIGUSR2)
+	upgradeExited := make(chan error, 1)
+	upgrading := false
+	for ctx.Err() == nil {
+		select {
+		case err := <-serverErr:
+			logger.Error("Error starting loadbalancer", "error", err)
+			os.Exit(1)
+		case <-upgradeSignals:
+			if upgrading {
+				logger.Warn("Upgrade is already running")
+				continue
+			}
+			logger.Info("Received SIGUSR2, starting the new process")
+			cmd, err := listeners.Upgrade()
+			if err != nil {
+				logger.Error("Error starting the new process", "error", err)
+				continue
+			}
+			upgrading = true
+			go func() {
+				upgradeExited <- cmd.Wait()
+			}()
+		case err := <-upgradeExited:
+			upgrading = false
+			logger.Error("The new process exited before taking over, upgrade failed", "error", err)
+		case <-ctx.Done():
+		}
+	}
+	// A second signal stops the load balancer right away
+	stop()
+
+	logger.Info("Shutting down")
+	// After an upgrade the new process serves on the same sockets, so readiness doesn't fail first
+	if err := serve.GracefulShutdown(reloader.Config().Shutdown, servers, ready, !upgrading, logger); err != nil {
+		logger.Warn("Grace period expired, closed remaining connections", "error", err)
+	}
+	logger.Info("Stopped load balancer")
+}
+
+func writePidFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePidFile removes the pid file, unless a new process took it over
+func removePidFile(path string) {
+	content, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(content)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	os.Remove(path)
+}
+
+func initLogger() (*os.File, error) {
+	timestamp := time.Now().Unix()
+	logFileName := fmt.Sprintf("log/loadbalancer-%d.log", timestamp)
+	file, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonHandler := slog.NewJSONHandler(file, nil)
+	slog.SetDefault(slog.New(jsonHandler))
+
+	return file, nil
+}